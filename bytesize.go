@@ -0,0 +1,133 @@
+package confx
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ByteSize is a size in bytes that is configured in human form, e.g. 512KiB, 10MB or 1.5GiB.
+//
+// It is parsed from flags, env vars and string values in config files by ParseByteSize,
+// while plain numbers in config files are taken as bytes. It is printed in the largest
+// unit that represents it exactly, so that help output and samples show defaults as written.
+type ByteSize uint64
+
+var typeByteSize = reflect.TypeOf(ByteSize(0))
+
+// Decimal byte size units.
+const (
+	Byte ByteSize = 1
+
+	KB ByteSize = 1000 * Byte
+	MB ByteSize = 1000 * KB
+	GB ByteSize = 1000 * MB
+	TB ByteSize = 1000 * GB
+	PB ByteSize = 1000 * TB
+	EB ByteSize = 1000 * PB
+)
+
+// Binary byte size units.
+const (
+	KiB ByteSize = 1 << (10 * (iota + 1))
+	MiB
+	GiB
+	TiB
+	PiB
+	EiB
+)
+
+// byteSizeUnits are the units accepted by ParseByteSize, keyed by their lowercase name.
+var byteSizeUnits = map[string]ByteSize{
+	"": Byte, "b": Byte,
+	"kb": KB, "mb": MB, "gb": GB, "tb": TB, "pb": PB, "eb": EB,
+	"kib": KiB, "mib": MiB, "gib": GiB, "tib": TiB, "pib": PiB, "eib": EiB,
+}
+
+var (
+	binaryByteSizeNames  = []string{"EiB", "PiB", "TiB", "GiB", "MiB", "KiB"}
+	binaryByteSizes      = []ByteSize{EiB, PiB, TiB, GiB, MiB, KiB}
+	decimalByteSizeNames = []string{"EB", "PB", "TB", "GB", "MB", "KB"}
+	decimalByteSizes     = []ByteSize{EB, PB, TB, GB, MB, KB}
+)
+
+// ParseByteSize parses a byte size such as 512, 512B, 10MB, 1.5GiB or 64 KiB. KB, MB, GB,
+// TB, PB and EB are powers of 1000, KiB, MiB, GiB, TiB, PiB and EiB are powers of 1024.
+// Units are case-insensitive, and fractional sizes are rounded to the nearest byte.
+func ParseByteSize(s string) (ByteSize, error) {
+	str := strings.TrimSpace(s)
+	i := strings.IndexFunc(str, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(str)
+	}
+	number, unitName := str[:i], strings.ToLower(strings.TrimSpace(str[i:]))
+	unit, ok := byteSizeUnits[unitName]
+	if number == "" || !ok {
+		return 0, errors.Errorf("invalid byte size %q", s)
+	}
+
+	if !strings.Contains(number, ".") {
+		n, err := strconv.ParseUint(number, 10, 64)
+		if err != nil || n > math.MaxUint64/uint64(unit) {
+			return 0, errors.Errorf("invalid byte size %q", s)
+		}
+		return ByteSize(n) * unit, nil
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid byte size %q", s)
+	}
+	size := math.Round(f * float64(unit))
+	if size >= math.MaxUint64 {
+		return 0, errors.Errorf("invalid byte size %q", s)
+	}
+	return ByteSize(size), nil
+}
+
+// String returns b in the largest binary, or else decimal, unit that represents it exactly, e.g. 512KiB or 10MB.
+func (b ByteSize) String() string {
+	if b == 0 {
+		return "0"
+	}
+	for i, unit := range binaryByteSizes {
+		if b%unit == 0 {
+			return strconv.FormatUint(uint64(b/unit), 10) + binaryByteSizeNames[i]
+		}
+	}
+	for i, unit := range decimalByteSizes {
+		if b%unit == 0 {
+			return strconv.FormatUint(uint64(b/unit), 10) + decimalByteSizeNames[i]
+		}
+	}
+	return strconv.FormatUint(uint64(b), 10) + "B"
+}
+
+// Set implements pflag.Value.
+func (b *ByteSize) Set(s string) error {
+	size, err := ParseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
+
+// Type implements pflag.Value.
+func (b *ByteSize) Type() string {
+	return "byteSize"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	return b.Set(string(text))
+}
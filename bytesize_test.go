@@ -0,0 +1,137 @@
+package confx_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/qor5/confx"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected confx.ByteSize
+		wantErr  bool
+	}{
+		{input: "0", expected: 0},
+		{input: "512", expected: 512},
+		{input: "512B", expected: 512},
+		{input: "512KiB", expected: 512 * confx.KiB},
+		{input: "10MB", expected: 10 * confx.MB},
+		{input: "10mb", expected: 10 * confx.MB},
+		{input: "1.5GiB", expected: 1536 * confx.MiB},
+		{input: " 64 KiB ", expected: 64 * confx.KiB},
+		{input: "0.5KB", expected: 500},
+		{input: "16EiB", wantErr: true},
+		{input: "18446744073709551616", wantErr: true},
+		{input: "", wantErr: true},
+		{input: "MB", wantErr: true},
+		{input: "10XB", wantErr: true},
+		{input: "-1MB", wantErr: true},
+		{input: "1.2.3MB", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			size, err := confx.ParseByteSize(tt.input)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "invalid byte size")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, size)
+		})
+	}
+}
+
+func TestByteSizeString(t *testing.T) {
+	tests := []struct {
+		size     confx.ByteSize
+		expected string
+	}{
+		{size: 0, expected: "0"},
+		{size: 100, expected: "100B"},
+		{size: 512 * confx.KiB, expected: "512KiB"},
+		{size: 1536 * confx.MiB, expected: "1536MiB"},
+		{size: 10 * confx.MB, expected: "10MB"},
+		{size: 2 * confx.KB, expected: "2KB"},
+		{size: confx.EiB, expected: "1EiB"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, tt.size.String())
+		parsed, err := confx.ParseByteSize(tt.size.String())
+		require.NoError(t, err)
+		assert.Equal(t, tt.size, parsed)
+	}
+}
+
+func TestByteSizeFields(t *testing.T) {
+	type Config struct {
+		CacheSize  confx.ByteSize   `confx:"cacheSize" usage:"Cache size"`
+		MaxBody    *confx.ByteSize  `confx:"maxBody"`
+		Tiers      []confx.ByteSize `confx:"tiers"`
+		BufferSize confx.ByteSize   `confx:"bufferSize"`
+	}
+	def := Config{
+		CacheSize: 512 * confx.MiB,
+		Tiers:     []confx.ByteSize{confx.KiB, confx.MiB},
+	}
+
+	fsys := fstest.MapFS{
+		"config.yaml": {Data: []byte(`cacheSize: 1.5GiB
+maxBody: 10MB
+tiers: [4KiB, 1048576]
+bufferSize: 4096
+`)},
+		"invalid.yaml": {Data: []byte("cacheSize: 10 parsecs\n")},
+	}
+	load := func(args []string, env map[string]string, path string) (Config, error) {
+		loader, err := confx.Initialize(def,
+			confx.WithArgs(args),
+			confx.WithFS(fsys),
+			confx.WithLookupEnv(func(key string) (string, bool) {
+				val, ok := env[key]
+				return val, ok
+			}),
+		)
+		require.NoError(t, err)
+		return loader(t.Context(), path)
+	}
+
+	conf, err := load(nil, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, 512*confx.MiB, conf.CacheSize)
+	assert.Equal(t, []confx.ByteSize{confx.KiB, confx.MiB}, conf.Tiers)
+
+	conf, err = load(nil, nil, "config.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, 1536*confx.MiB, conf.CacheSize)
+	assert.Equal(t, 10*confx.MB, *conf.MaxBody)
+	assert.Equal(t, []confx.ByteSize{4 * confx.KiB, confx.MiB}, conf.Tiers)
+	assert.Equal(t, 4*confx.KiB, conf.BufferSize)
+
+	conf, err = load([]string{"--cache-size", "2GiB", "--tiers", "1KB,2KB"}, map[string]string{"MAX_BODY": "1MiB", "BUFFER_SIZE": "8KiB"}, "config.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, 2*confx.GiB, conf.CacheSize)
+	assert.Equal(t, confx.MiB, *conf.MaxBody)
+	assert.Equal(t, []confx.ByteSize{confx.KB, 2 * confx.KB}, conf.Tiers)
+	assert.Equal(t, 8*confx.KiB, conf.BufferSize)
+
+	_, err = load([]string{"--cache-size", "lots"}, nil, "")
+	assert.ErrorContains(t, err, `invalid argument "lots" for "--cache-size" flag: invalid byte size "lots"`)
+
+	_, err = load(nil, nil, "invalid.yaml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `'cacheSize'`)
+	assert.Contains(t, err.Error(), `invalid byte size "10 parsecs"`)
+
+	flagSet := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	_, err = confx.Initialize(def, confx.WithFlagSet(flagSet))
+	require.NoError(t, err)
+	usage := flagSet.FlagUsages()
+	assert.Regexp(t, `--cache-size byteSize\s+Cache size \(default 512MiB\)`, usage)
+	assert.Regexp(t, `--tiers strings\s+tiers \(default \[1KiB,1MiB\]\)`, usage)
+	assert.NotContains(t, usage, "bufferSize (default")
+}
@@ -0,0 +1,101 @@
+package confx
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// isNestedType reports whether t is a collection of collections or structs, such as [][]int,
+// map[string][]string or [2]map[string]int, whose elements are all supported. Flags and env vars
+// take these in their JSON form.
+func isNestedType(t reflect.Type) bool {
+	return isCollectionType(t) && !isScalarType(unwrapType(t.Elem()))
+}
+
+// isCollectionType reports whether t is a slice, array or map with string keys of scalars,
+// structs or supported collections.
+func isCollectionType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return false
+		}
+	default:
+		return false
+	}
+	elemType := unwrapType(t.Elem())
+	return isScalarType(elemType) || elemType.Kind() == reflect.Struct || isCollectionType(elemType)
+}
+
+// flagSetJSON registers a flag that takes the JSON form of the nested collection fieldValue.
+// The default is written in the same plain form as config samples, and is empty for the zero value.
+// It holds the real values of secrets, which are masked in help output.
+func flagSetJSON(flagSet *pflag.FlagSet, fieldValue reflect.Value, flagKey, usage, tagName string) error {
+	value := &jsonValue{}
+	if fieldValue.IsZero() {
+		flagSet.Var(value, flagKey, usage)
+		return nil
+	}
+	text, defValue, err := flagJSONDefault(fieldValue, flagKey, tagName)
+	if err != nil {
+		return err
+	}
+	value.text = text
+	flagSet.Var(value, flagKey, usage)
+	flagSet.Lookup(flagKey).DefValue = defValue
+	return nil
+}
+
+// flagJSONDefault returns the JSON form of the default fieldValue of a flag, and the same form
+// with its secrets masked for help output.
+func flagJSONDefault(fieldValue reflect.Value, flagKey, tagName string) (string, string, error) {
+	bs, err := json.Marshal(plainValue(fieldValue, tagName, secretRevealed))
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to marshal json, key %q", flagKey)
+	}
+	masked, err := json.Marshal(plainValue(fieldValue, tagName, secretMasked))
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to marshal json, key %q", flagKey)
+	}
+	return string(bs), string(masked), nil
+}
+
+// jsonValue is the pflag.Value of a nested collection. It validates the JSON when set and keeps
+// its text, which is decoded by decodeJSON once all sources are merged.
+type jsonValue struct {
+	text string
+}
+
+func (j *jsonValue) Set(s string) error {
+	if !json.Valid([]byte(s)) {
+		return errors.New("invalid json")
+	}
+	j.text = s
+	return nil
+}
+
+func (j *jsonValue) String() string {
+	return j.text
+}
+
+func (j *jsonValue) Type() string {
+	return "json"
+}
+
+// decodeJSON decodes the JSON form s of a nested collection into plain values, which the decode
+// hooks then decode into to like the values of a JSON config file. The empty string is the zero value.
+func decodeJSON(to reflect.Type, s string) (any, error) {
+	if strings.TrimSpace(s) == "" {
+		return reflect.Zero(to).Interface(), nil
+	}
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal json, data: %s", s)
+	}
+	return v, nil
+}
@@ -0,0 +1,193 @@
+package confx_test
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/qor5/confx"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Replica struct {
+	Host string        `confx:"host"`
+	Lag  time.Duration `confx:"lag"`
+}
+
+type ShardConfig struct {
+	Zones      [3]string                  `confx:"zones" usage:"Zones of the shards"`
+	Weights    [2]uint8                   `confx:"weights"`
+	Layout     [][]int                    `confx:"layout" usage:"Shard layout"`
+	Allowlists map[string][]string        `confx:"allowlists"`
+	Windows    map[string][]time.Duration `confx:"windows"`
+	Replicas   [2]Replica                 `confx:"replicas"`
+	Groups     []map[string]int           `confx:"groups"`
+}
+
+func TestNestedCollections(t *testing.T) {
+	def := ShardConfig{
+		Zones:      [3]string{"a", "b", "c"},
+		Layout:     [][]int{{0, 1}, {2}},
+		Allowlists: map[string][]string{"acme": {"10.0.0.1"}},
+		Replicas:   [2]Replica{{Host: "db-1", Lag: time.Second}},
+	}
+	fsys := fstest.MapFS{
+		"config.yaml": {Data: []byte(`
+zones: [x, y]
+weights: [1, 2]
+layout:
+  - [1, 2, 3]
+  - []
+allowlists:
+  acme: [10.0.0.2, 10.0.0.3]
+  globex: []
+windows:
+  nightly: [1h, 1d]
+replicas:
+  - host: db-2
+    lag: 1m
+groups:
+  - {a: 1}
+`)},
+	}
+	load := func(t *testing.T, args []string, env map[string]string, path string) (ShardConfig, error) {
+		t.Helper()
+		flagSet := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		loader, err := confx.Initialize(def,
+			confx.WithFlagSet(flagSet),
+			confx.WithArgs(args),
+			confx.WithFS(fsys),
+			confx.WithLookupEnv(func(key string) (string, bool) {
+				val, ok := env[key]
+				return val, ok
+			}),
+		)
+		require.NoError(t, err)
+		return loader(t.Context(), path)
+	}
+	want := ShardConfig{
+		Zones:      [3]string{"x", "y"},
+		Weights:    [2]uint8{1, 2},
+		Layout:     [][]int{{1, 2, 3}, {}},
+		Allowlists: map[string][]string{"acme": {"10.0.0.2", "10.0.0.3"}, "globex": {}},
+		Windows:    map[string][]time.Duration{"nightly": {time.Hour, confx.Day}},
+		Replicas:   [2]Replica{{Host: "db-2", Lag: time.Minute}},
+		Groups:     []map[string]int{{"a": 1}},
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		conf, err := load(t, nil, nil, "")
+		require.NoError(t, err)
+		assert.Equal(t, def, conf)
+	})
+
+	t.Run("flags", func(t *testing.T) {
+		conf, err := load(t, []string{
+			"--zones", "x,y",
+			"--weights", "1", "--weights", "2",
+			"--layout", "[[1,2,3],[]]",
+			"--allowlists", `{"acme":["10.0.0.2","10.0.0.3"],"globex":[]}`,
+			"--windows", `{"nightly":["1h","1d"]}`,
+			"--replicas", `[{"host":"db-2","lag":"1m"}]`,
+			"--groups", `[{"a":1}]`,
+		}, nil, "")
+		require.NoError(t, err)
+		assert.Equal(t, want, conf)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		conf, err := load(t, nil, map[string]string{
+			"ZONES":      "x,y",
+			"WEIGHTS":    "1,2",
+			"LAYOUT":     "[[1,2,3],[]]",
+			"ALLOWLISTS": `{"acme":["10.0.0.2","10.0.0.3"],"globex":[]}`,
+			"WINDOWS":    `{"nightly":["1h","1d"]}`,
+			"REPLICAS":   `[{"host":"db-2","lag":"1m"}]`,
+			"GROUPS":     `[{"a":1}]`,
+		}, "")
+		require.NoError(t, err)
+		assert.Equal(t, want, conf)
+	})
+
+	t.Run("config file", func(t *testing.T) {
+		conf, err := load(t, nil, nil, "config.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, want, conf)
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		flagSet := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		_, err := confx.Initialize(def, confx.WithFlagSet(flagSet))
+		require.NoError(t, err)
+		usage := flagSet.FlagUsages()
+		assert.Regexp(t, `--zones \[3\]string\s+Zones of the shards \(default \[a,b,c\]\)`, usage)
+		assert.Regexp(t, `--layout json\s+Shard layout \(default \[\[0,1\],\[2\]\]\)`, usage)
+		assert.Regexp(t, `--replicas json\s+replicas \(default \[\{"host":"db-1","lag":"1s"\},\{"host":"","lag":"0s"\}\]\)`, usage)
+		assert.Regexp(t, `--windows json\s+windows\n`, usage)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			args    []string
+			env     map[string]string
+			wantErr string
+		}{
+			{
+				name:    "too many array values",
+				args:    []string{"--zones", "a,b", "--zones", "c,d"},
+				wantErr: `invalid argument "c,d" for "--zones" flag: too many values, the array has length 3`,
+			},
+			{
+				name:    "invalid array element",
+				args:    []string{"--weights", "1,256"},
+				wantErr: `invalid argument "1,256" for "--weights" flag: invalid value "256"`,
+			},
+			{
+				name:    "invalid json flag",
+				args:    []string{"--layout", "1,2"},
+				wantErr: `invalid argument "1,2" for "--layout" flag: invalid json`,
+			},
+			{
+				name:    "too many array values in env",
+				env:     map[string]string{"ZONES": "a,b,c,d"},
+				wantErr: "too many values for [3]string: 4",
+			},
+			{
+				name:    "invalid json env",
+				env:     map[string]string{"ALLOWLISTS": "acme=10.0.0.1"},
+				wantErr: "failed to unmarshal json",
+			},
+			{
+				name:    "invalid nested element",
+				env:     map[string]string{"WINDOWS": `{"nightly":["soon"]}`},
+				wantErr: `invalid duration "soon"`,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := load(t, tt.args, tt.env, "")
+				assert.ErrorContains(t, err, tt.wantErr)
+			})
+		}
+	})
+}
+
+func TestNestedCollectionsUnsupported(t *testing.T) {
+	_, err := confx.Initialize(struct {
+		Funcs [][]func() `confx:"funcs"`
+	}{})
+	assert.ErrorContains(t, err, `flag key "funcs": unsupported slice element type: "[]func()"`)
+
+	_, err = confx.Initialize(struct {
+		Chans [2]chan int `confx:"chans"`
+	}{})
+	assert.ErrorContains(t, err, `flag key "chans": unsupported array element type: "chan int"`)
+
+	_, err = confx.Initialize(struct {
+		Lists map[string][]chan int `confx:"lists"`
+	}{})
+	assert.ErrorContains(t, err, `flag key "lists": unsupported map value type "[]chan int"`)
+}
@@ -2,8 +2,8 @@ package confx
 
 import (
 	"context"
-	"encoding/json"
 	"go/ast"
+	"net/netip"
 	"os"
 	"reflect"
 	"regexp"
@@ -11,7 +11,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/huandu/go-clone"
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
@@ -41,43 +40,145 @@ type Loader[T any] func(ctx context.Context, confPath string) (T, error)
 //     When invoked, it parses the command-line flags, binds them along with environment
 //     variables, loads the configuration file if provided, unmarshal the configuration
 //     into the struct, and validates it.
+//     The path may be a comma-separated list of files, and --config may be repeated; the files
+//     are deep-merged in order over the defaults, before env vars and flags are applied.
+//     Without any path, the config file is looked up as set by WithConfigSearch.
 //   - error: An error object if initialization fails.
 func Initialize[T any](def T, options ...Option) (Loader[T], error) {
-	opts := &initOptions{
-		flagSet:       nil,
-		envPrefix:     "",
-		tagName:       DefaultTagName,
-		usageTagName:  DefaultUsageTagName,
-		viperInstance: viper.GetViper(),
-		validator:     validator.New(validator.WithRequiredStructEnabled()),
-	}
-	for _, opt := range options {
-		opt(opts)
-	}
-	def = clone.Slowly(def).(T)
+	opts := newInitOptions(options...)
+	def = cloneSlowly(def)
 
-	var flagConfig string
+	var flagConfig []string
 	if opts.flagSet == nil {
 		opts.flagSet = pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
 		opts.flagSet.SortFlags = false
-		opts.flagSet.StringVarP(&flagConfig, "config", "c", "", "Path to configuration file")
+		opts.flagSet.StringSliceVarP(&flagConfig, "config", "c", nil, "Path to configuration file, repeat to merge several files in order")
 	}
 
 	var collectBinds []func() error
-	err := initializeRecursive(opts, reflect.ValueOf(def), "", &collectBinds)
+	var collectFields []*fieldInfo
+	err := initializeRecursive(opts, reflect.ValueOf(def), "", &collectBinds, &collectFields)
 	if err != nil {
 		return nil, err
 	}
+	if opts.profiles && opts.flagSet.Lookup(profileFlagName) == nil {
+		opts.flagSet.String(profileFlagName, "", "Configuration profile to layer on top of the config files")
+	}
+	if opts.envFiles != nil && opts.flagSet.Lookup(envFileFlagName) == nil {
+		opts.flagSet.StringSlice(envFileFlagName, opts.envFiles, "Path to dotenv file, repeat to read several files in order")
+	}
 
+	keyTree := newKeyTree(collectFields)
 	enhancedValidator := ValidatorWithSkipNestedUnless(opts.validator)
 
+	// The viper instance is mutated by every load, so loads are serialized.
+	var mu sync.Mutex
+	envApplied := map[string]bool{}
+	unmarshal := func(ctx context.Context, confPath string) (T, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var zero T
+
+		envFiles, err := readEnvFiles(opts)
+		if err != nil {
+			return zero, err
+		}
+		lookupEnv := withEnvFiles(opts.lookupEnv, envFiles)
+		fileEnv, err := readFileEnv(lookupEnv, collectFields)
+		if err != nil {
+			return zero, err
+		}
+		lookupEnv = withFileEnv(lookupEnv, fileEnv)
+		applyEnv(opts, lookupEnv, collectFields, envApplied)
+
+		paths := splitConfigPaths(confPath)
+		if len(paths) == 0 {
+			paths = flagConfig
+		}
+		if len(paths) == 0 {
+			paths = searchConfigFiles(opts)
+		}
+
+		profile, err := resolveProfile(opts, lookupEnv)
+		if err != nil {
+			return zero, err
+		}
+
+		var layers []*configLayer
+		if len(paths) > 0 {
+			if layers, err = readConfigFiles(opts, paths, profile, lookupEnv, isKeyReference); err != nil {
+				return zero, err
+			}
+		}
+
+		if opts.strict {
+			if unknown := findUnknownFileKeys(collectFields, layers); len(unknown) > 0 {
+				return zero, &UnknownKeysError{Keys: unknown}
+			}
+		}
+
+		var unknownEnv []UnknownKey
+		if opts.envCheck {
+			unknownEnv = findUnknownEnvKeys(opts, collectFields, opts.environ(), envFiles)
+			if opts.envCheckFail && len(unknownEnv) > 0 {
+				return zero, &UnknownKeysError{Keys: unknownEnv}
+			}
+		}
+
+		provenance := buildProvenance(opts.flagSet, opts.lookupEnv, fileEnv, envFiles, collectFields, layers)
+		if report := loadReportFromContext(ctx); report != nil {
+			report.Profile = profile
+			report.UnknownKeys = unknownEnv
+			report.ConfigFiles = nil
+			for _, layer := range layers {
+				report.ConfigFiles = append(report.ConfigFiles, layer.path)
+			}
+			report.configDirs = configDirs(opts, paths)
+			report.fs = opts.fs != nil
+			report.Provenance = provenance
+		}
+
+		// Secret files, secrets, encrypted values and references to other keys are resolved once all
+		// sources are merged. Secrets come first, so that references to them copy their plaintext.
+		// Only config files reference other keys, the values of the other sources are kept as is.
+		settings := opts.viperInstance.AllSettings()
+		escapeSourceValues(settings, provenance)
+		if err := resolveSecretFiles(settings, lookupEnv, collectFields); err != nil {
+			return zero, errors.Wrap(err, "failed to read secret file")
+		}
+		if err := resolveSecretRefs(ctx, settings, opts.resolvers, lookupEnv); err != nil {
+			return zero, errors.Wrap(err, "failed to resolve secret")
+		}
+		if err := decryptSettings(settings, ageIdentities(lookupEnv, opts.ageKeyFile)); err != nil {
+			return zero, errors.Wrap(err, "failed to decrypt config")
+		}
+		if err := resolveKeyReferences(keyTree, settings); err != nil {
+			return zero, errors.Wrap(err, "failed to resolve config references")
+		}
+		resolved := viper.New()
+		if err := resolved.MergeConfigMap(settings); err != nil {
+			return zero, errors.Wrap(err, "failed to resolve config references")
+		}
+
+		var conf T
+		if err := resolved.Unmarshal(&conf, DecoderConfigOption(opts.tagName)); err != nil {
+			return zero, errors.Wrapf(err, "failed to unmarshal config to %T", conf)
+		}
+		return conf, nil
+	}
+
 	var once sync.Once
 	var onceErr error
 	return func(ctx context.Context, confPath string) (T, error) {
 		once.Do(func() {
 			if !opts.flagSet.Parsed() {
-				if err := opts.flagSet.Parse(os.Args[1:]); err != nil {
-					if errors.Is(err, pflag.ErrHelp) {
+				args := opts.args
+				if args == nil {
+					args = os.Args[1:]
+				}
+				if err := opts.flagSet.Parse(args); err != nil {
+					if errors.Is(err, pflag.ErrHelp) && !opts.helpError {
 						os.Exit(0)
 					}
 					onceErr = errors.Wrap(err, "failed to parse flags")
@@ -97,20 +198,9 @@ func Initialize[T any](def T, options ...Option) (Loader[T], error) {
 			return zero, onceErr
 		}
 
-		if confPath == "" {
-			confPath = flagConfig
-		}
-
-		if confPath != "" {
-			opts.viperInstance.SetConfigFile(confPath)
-			if err := opts.viperInstance.ReadInConfig(); err != nil {
-				return zero, errors.Wrapf(err, "failed to read config %q", confPath)
-			}
-		}
-
-		var conf T
-		if err := opts.viperInstance.Unmarshal(&conf, DecoderConfigOption(opts.tagName)); err != nil {
-			return zero, errors.Wrapf(err, "failed to unmarshal config to %T", conf)
+		conf, err := unmarshal(ctx, confPath)
+		if err != nil {
+			return zero, err
 		}
 
 		if err := enhancedValidator.StructCtx(ctx, conf); err != nil {
@@ -121,6 +211,79 @@ func Initialize[T any](def T, options ...Option) (Loader[T], error) {
 	}, nil
 }
 
+// applyEnv copies the env var values of the registered fields, as returned by lookupEnv, into the
+// override layer of viper, since viper itself can only read the process environment. Env vars set
+// to an empty value are ignored, and flags passed on the command line keep precedence over env vars.
+// applied tracks the keys set by previous loads, so that they are cleared once the env var is gone.
+func applyEnv(opts *initOptions, lookupEnv func(string) (string, bool), fields []*fieldInfo, applied map[string]bool) {
+	for _, f := range fields {
+		if f.nested {
+			continue
+		}
+		val, ok := lookupEnv(f.EnvKey)
+		if flag := opts.flagSet.Lookup(f.FlagKey); flag != nil && flag.Changed {
+			ok = false
+		}
+		switch {
+		case ok && val != "":
+			opts.viperInstance.Set(f.ViperKey, val)
+			applied[f.ViperKey] = true
+		case applied[f.ViperKey]:
+			opts.viperInstance.Set(f.ViperKey, nil)
+			delete(applied, f.ViperKey)
+		}
+	}
+}
+
+// fieldInfo describes a configuration field registered by initializeRecursive.
+// Nested struct fields are recorded as well, right before their own fields, but
+// they have no flag or env binding.
+type fieldInfo struct {
+	*Field
+	structField reflect.StructField
+	typ         reflect.Type  // field type with pointers and Secret unwrapped
+	value       reflect.Value // default value
+	secret      bool
+	nested      bool
+}
+
+// keyNode is a node of the tree formed by the viper keys of the registered fields.
+type keyNode struct {
+	key      string
+	field    *fieldInfo // nil for the root
+	children []*keyNode
+}
+
+func newKeyTree(fields []*fieldInfo) *keyNode {
+	root := &keyNode{}
+	for _, f := range fields {
+		root.insert(strings.Split(f.ViperKey, "."), f)
+	}
+	return root
+}
+
+func (n *keyNode) insert(path []string, f *fieldInfo) {
+	if len(path) > 1 {
+		for _, child := range n.children {
+			if child.key == path[0] && !child.isLeaf() {
+				child.insert(path[1:], f)
+				return
+			}
+		}
+	}
+	child := &keyNode{key: path[0]}
+	n.children = append(n.children, child)
+	if len(path) == 1 {
+		child.field = f
+		return
+	}
+	child.insert(path[1:], f)
+}
+
+func (n *keyNode) isLeaf() bool {
+	return n.field != nil && !n.field.nested
+}
+
 var envReplacer = strings.NewReplacer(".", "_", "-", "_")
 
 // unwrapOrNew dereferences a pointer type reflect.Value until a non-pointer
@@ -137,6 +300,19 @@ func unwrapOrNew(v reflect.Value) reflect.Value {
 	return v
 }
 
+// cloner deep-copies configuration values. netip.Addr compares its internal handle by
+// pointer, so it is copied as is.
+var cloner = func() *clone.Allocator {
+	a := clone.FromHeap()
+	a.MarkAsScalar(reflect.TypeOf(netip.Addr{}))
+	return a
+}()
+
+// cloneSlowly deep-copies v with cloner, supporting cyclic pointers.
+func cloneSlowly[T any](v T) T {
+	return cloner.CloneSlowly(reflect.ValueOf(&v).Elem()).Interface().(T)
+}
+
 func unwrapType(t reflect.Type) reflect.Type {
 	for t.Kind() == reflect.Ptr {
 		t = t.Elem()
@@ -156,6 +332,7 @@ func initializeRecursive(
 	v reflect.Value,
 	parentKey string,
 	collectBinds *[]func() error,
+	collectFields *[]*fieldInfo,
 ) error {
 	v = unwrapOrNew(v)
 	if v.Kind() != reflect.Struct {
@@ -180,7 +357,7 @@ func initializeRecursive(
 			if fieldType.Kind() != reflect.Struct || fieldType == typeTime {
 				return errors.Errorf("unsupported squash type: %q", fieldType)
 			}
-			if err := initializeRecursive(opts, fieldValue, parentKey, collectBinds); err != nil {
+			if err := initializeRecursive(opts, fieldValue, parentKey, collectBinds, collectFields); err != nil {
 				return err
 			}
 			continue
@@ -210,70 +387,105 @@ func initializeRecursive(
 			viperKey, flagKey, envKey, usage = f.ViperKey, f.FlagKey, f.EnvKey, f.Usage
 		}
 
-		switch fieldType.Kind() {
-		case reflect.Bool:
-			opts.flagSet.Bool(flagKey, fieldValue.Bool(), usage)
-		case reflect.Float32:
-			opts.flagSet.Float32(flagKey, float32(fieldValue.Float()), usage)
-		case reflect.Float64:
-			opts.flagSet.Float64(flagKey, fieldValue.Float(), usage)
-		case reflect.Int:
-			opts.flagSet.Int(flagKey, int(fieldValue.Int()), usage)
-		case reflect.Int8:
-			opts.flagSet.Int8(flagKey, int8(fieldValue.Int()), usage)
-		case reflect.Int16:
-			opts.flagSet.Int16(flagKey, int16(fieldValue.Int()), usage)
-		case reflect.Int32:
-			opts.flagSet.Int32(flagKey, int32(fieldValue.Int()), usage)
-		case reflect.Int64:
-			if fieldType == typeDuration {
-				opts.flagSet.Duration(flagKey, fieldValue.Interface().(time.Duration), usage)
-			} else {
-				opts.flagSet.Int64(flagKey, fieldValue.Int(), usage)
-			}
-		case reflect.String:
-			opts.flagSet.String(flagKey, fieldValue.String(), usage)
-		case reflect.Uint:
-			opts.flagSet.Uint(flagKey, uint(fieldValue.Uint()), usage)
-		case reflect.Uint8:
-			opts.flagSet.Uint8(flagKey, uint8(fieldValue.Uint()), usage)
-		case reflect.Uint16:
-			opts.flagSet.Uint16(flagKey, uint16(fieldValue.Uint()), usage)
-		case reflect.Uint32:
-			opts.flagSet.Uint32(flagKey, uint32(fieldValue.Uint()), usage)
-		case reflect.Uint64:
-			opts.flagSet.Uint64(flagKey, fieldValue.Uint(), usage)
-		case reflect.Slice:
-			if err := flagSetSlice(opts.flagSet, fieldValue, flagKey, usage); err != nil {
-				return err
-			}
-		case reflect.Map:
-			if err := flagSetMap(opts.flagSet, fieldValue, flagKey, usage); err != nil {
-				return err
-			}
-		case reflect.Struct:
-			if fieldType == typeTime {
-				opts.flagSet.String(flagKey, fieldValue.Interface().(time.Time).Format(time.RFC3339), usage+" (time in RFC3339 format)")
-			} else {
-				if err := initializeRecursive(opts, fieldValue, viperKey, collectBinds); err != nil {
+		secret := isSecretField(field)
+		if isSecretType(fieldType) {
+			fieldValue = unwrapOrNew(fieldValue.Interface().(secretValuer).secretValue())
+			fieldType = fieldValue.Type()
+		}
+
+		if isTextType(fieldType) {
+			// Domain types such as log levels or endpoints are set from a single string.
+			opts.flagSet.Var(newTextFlagValue(fieldValue), flagKey, usage)
+		} else {
+			switch fieldType.Kind() {
+			case reflect.Bool:
+				opts.flagSet.Bool(flagKey, fieldValue.Bool(), usage)
+			case reflect.Float32:
+				opts.flagSet.Float32(flagKey, float32(fieldValue.Float()), usage)
+			case reflect.Float64:
+				opts.flagSet.Float64(flagKey, fieldValue.Float(), usage)
+			case reflect.Int:
+				opts.flagSet.Int(flagKey, int(fieldValue.Int()), usage)
+			case reflect.Int8:
+				opts.flagSet.Int8(flagKey, int8(fieldValue.Int()), usage)
+			case reflect.Int16:
+				opts.flagSet.Int16(flagKey, int16(fieldValue.Int()), usage)
+			case reflect.Int32:
+				opts.flagSet.Int32(flagKey, int32(fieldValue.Int()), usage)
+			case reflect.Int64:
+				if fieldType == typeDuration {
+					d := durationValue(fieldValue.Int())
+					opts.flagSet.Var(&d, flagKey, usage)
+				} else {
+					opts.flagSet.Int64(flagKey, fieldValue.Int(), usage)
+				}
+			case reflect.String:
+				opts.flagSet.String(flagKey, fieldValue.String(), usage)
+			case reflect.Uint:
+				opts.flagSet.Uint(flagKey, uint(fieldValue.Uint()), usage)
+			case reflect.Uint8:
+				opts.flagSet.Uint8(flagKey, uint8(fieldValue.Uint()), usage)
+			case reflect.Uint16:
+				opts.flagSet.Uint16(flagKey, uint16(fieldValue.Uint()), usage)
+			case reflect.Uint32:
+				opts.flagSet.Uint32(flagKey, uint32(fieldValue.Uint()), usage)
+			case reflect.Uint64:
+				opts.flagSet.Uint64(flagKey, fieldValue.Uint(), usage)
+			case reflect.Slice, reflect.Array:
+				if err := flagSetSlice(opts.flagSet, fieldValue, flagKey, usage, opts.tagName); err != nil {
+					return err
+				}
+			case reflect.Map:
+				if err := flagSetMap(opts.flagSet, fieldValue, flagKey, usage, opts.tagName); err != nil {
 					return err
 				}
-				continue
+			case reflect.Struct:
+				if fieldType == typeTime {
+					opts.flagSet.String(flagKey, fieldValue.Interface().(time.Time).Format(time.RFC3339), usage+" (time in RFC3339 format)")
+				} else {
+					*collectFields = append(*collectFields, &fieldInfo{
+						Field: &Field{
+							ViperKey: viperKey,
+							FlagKey:  flagKey,
+							EnvKey:   envKey,
+							Usage:    usage,
+						},
+						structField: field,
+						typ:         fieldType,
+						value:       fieldValue,
+						nested:      true,
+					})
+					if err := initializeRecursive(opts, fieldValue, viperKey, collectBinds, collectFields); err != nil {
+						return err
+					}
+					continue
+				}
+			default:
+				return errors.Errorf("unsupported field type %q (%s) for key %q", fieldType, fieldType.Kind(), viperKey)
 			}
-		default:
-			return errors.Errorf("unsupported field type %q (%s) for key %q", fieldType, fieldType.Kind(), viperKey)
 		}
 
-		*collectBinds = append(*collectBinds, func() error {
-			if err := opts.viperInstance.BindPFlag(viperKey, opts.flagSet.Lookup(flagKey)); err != nil {
-				return errors.Wrapf(err, "failed to bind flag %q", flagKey)
-			}
-			return nil
+		if secret && !fieldValue.IsZero() {
+			// Keep the real default as the flag value but never show it in help output.
+			opts.flagSet.Lookup(flagKey).DefValue = SecretMask
+		}
+
+		*collectFields = append(*collectFields, &fieldInfo{
+			Field: &Field{
+				ViperKey: viperKey,
+				FlagKey:  flagKey,
+				EnvKey:   envKey,
+				Usage:    usage,
+			},
+			structField: field,
+			typ:         fieldType,
+			value:       fieldValue,
+			secret:      secret,
 		})
 
 		*collectBinds = append(*collectBinds, func() error {
-			if err := opts.viperInstance.BindEnv(viperKey, envKey); err != nil {
-				return errors.Wrapf(err, "failed to bind env %q", envKey)
+			if err := opts.viperInstance.BindPFlag(viperKey, opts.flagSet.Lookup(flagKey)); err != nil {
+				return errors.Wrapf(err, "failed to bind flag %q", flagKey)
 			}
 			return nil
 		})
@@ -290,8 +502,23 @@ func convertSlice[T any](fieldValue reflect.Value, convertFunc func(reflect.Valu
 	return slice
 }
 
-func flagSetSlice(flagSet *pflag.FlagSet, fieldValue reflect.Value, flagKey, usage string) error {
+func flagSetSlice(flagSet *pflag.FlagSet, fieldValue reflect.Value, flagKey, usage, tagName string) error {
 	elemType := fieldValue.Type().Elem()
+	if fieldValue.Kind() == reflect.Array {
+		switch {
+		case isScalarType(elemType):
+			flagSet.Var(newSliceValue(fieldValue), flagKey, usage)
+		case isNestedType(fieldValue.Type()):
+			return flagSetJSON(flagSet, fieldValue, flagKey, usage, tagName)
+		default:
+			return errors.Errorf("flag key %q: unsupported array element type: %q", flagKey, elemType)
+		}
+		return nil
+	}
+	if isTextType(elemType) {
+		flagSet.StringSlice(flagKey, convertSlice(fieldValue, formatText), usage)
+		return nil
+	}
 	switch elemType.Kind() {
 	case reflect.Bool:
 		flagSet.BoolSlice(flagKey, convertSlice(fieldValue, func(v reflect.Value) bool {
@@ -315,8 +542,9 @@ func flagSetSlice(flagSet *pflag.FlagSet, fieldValue reflect.Value, flagKey, usa
 		}), usage)
 	case reflect.Int64:
 		if elemType == typeDuration {
-			flagSet.DurationSlice(flagKey, convertSlice(fieldValue, func(v reflect.Value) time.Duration {
-				return time.Duration(v.Int())
+			// Registered as strings, so that the units of ParseDuration are accepted.
+			flagSet.StringSlice(flagKey, convertSlice(fieldValue, func(v reflect.Value) string {
+				return FormatDuration(time.Duration(v.Int()))
 			}), usage)
 		} else {
 			flagSet.Int64Slice(flagKey, convertSlice(fieldValue, func(v reflect.Value) int64 {
@@ -327,20 +555,29 @@ func flagSetSlice(flagSet *pflag.FlagSet, fieldValue reflect.Value, flagKey, usa
 		flagSet.StringSlice(flagKey, convertSlice(fieldValue, func(v reflect.Value) string {
 			return v.String()
 		}), usage)
-	case reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Uint8:
+	case reflect.Uint:
+		flagSet.UintSlice(flagKey, convertSlice(fieldValue, func(v reflect.Value) uint {
+			return uint(v.Uint())
+		}), usage)
+	case reflect.Uint8:
 		if fieldValue.Type() == reflect.TypeOf([]byte{}) {
 			flagSet.BytesBase64(flagKey, fieldValue.Bytes(), usage)
 		} else {
-			flagSet.UintSlice(flagKey, convertSlice(fieldValue, func(v reflect.Value) uint {
-				return uint(v.Uint())
-			}), usage)
+			flagSet.Var(newSliceValue(fieldValue), flagKey, usage)
 		}
+	case reflect.Int8, reflect.Int16, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		flagSet.Var(newSliceValue(fieldValue), flagKey, usage)
 	case reflect.Struct:
-		bs, err := json.Marshal(fieldValue.Interface())
-		if err != nil {
-			return errors.Wrapf(err, "failed to marshal json, key %q", flagKey)
+		if elemType == typeTime {
+			flagSet.Var(newSliceValue(fieldValue), flagKey, usage)
+			break
+		}
+		return flagSetJSON(flagSet, fieldValue, flagKey, usage, tagName)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if !isNestedType(fieldValue.Type()) {
+			return errors.Errorf("flag key %q: unsupported slice element type: %q", flagKey, elemType)
 		}
-		flagSet.String(flagKey, string(bs), usage)
+		return flagSetJSON(flagSet, fieldValue, flagKey, usage, tagName)
 	default:
 		return errors.Errorf("flag key %q: unsupported slice element type: %q", flagKey, elemType)
 	}
@@ -355,28 +592,36 @@ func convertMap[T any](fieldValue reflect.Value, convertFunc func(reflect.Value)
 	return m
 }
 
-func flagSetMap(flagSet *pflag.FlagSet, fieldValue reflect.Value, flagKey, usage string) error {
+func flagSetMap(flagSet *pflag.FlagSet, fieldValue reflect.Value, flagKey, usage, tagName string) error {
 	keyType := fieldValue.Type().Key()
 	if keyType.Kind() != reflect.String {
 		return errors.Errorf("flag key %q: only string keys are supported for map type, but got %q", flagKey, keyType)
 	}
 
 	elemType := fieldValue.Type().Elem()
-	switch elemType.Kind() {
-	case reflect.Int:
+	if isNestedType(fieldValue.Type()) {
+		return flagSetJSON(flagSet, fieldValue, flagKey, usage, tagName)
+	}
+	if !isScalarType(elemType) {
+		return errors.Errorf("flag key %q: unsupported map value type %q", flagKey, elemType)
+	}
+	switch {
+	case isTextType(elemType) || elemType == typeDuration:
+		flagSet.Var(newMapValue(fieldValue), flagKey, usage)
+	case elemType.Kind() == reflect.Int:
 		flagSet.StringToInt(flagKey, convertMap(fieldValue, func(v reflect.Value) int {
 			return int(v.Int())
 		}), usage)
-	case reflect.Int64:
+	case elemType.Kind() == reflect.Int64:
 		flagSet.StringToInt64(flagKey, convertMap(fieldValue, func(v reflect.Value) int64 {
 			return v.Int()
 		}), usage)
-	case reflect.String:
+	case elemType.Kind() == reflect.String:
 		flagSet.StringToString(flagKey, convertMap(fieldValue, func(v reflect.Value) string {
 			return v.String()
 		}), usage)
 	default:
-		return errors.Errorf("flag key %q: unsupported map value type %q", flagKey, elemType)
+		flagSet.Var(newMapValue(fieldValue), flagKey, usage)
 	}
 	return nil
 }
@@ -1,12 +1,16 @@
 package confx_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/qor5/confx"
@@ -808,3 +812,191 @@ func TestWithTagName(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, def, conf)
 }
+
+func TestHelpError(t *testing.T) {
+	viper.Reset()
+
+	type Config struct {
+		Port int `confx:"port" usage:"Server port"`
+	}
+
+	var usage bytes.Buffer
+	flagSet := pflag.NewFlagSet("test_help_error", pflag.ContinueOnError)
+	flagSet.SetOutput(&usage)
+	loader, err := confx.Initialize(Config{Port: 8080},
+		confx.WithFlagSet(flagSet),
+		confx.WithArgs([]string{"--help"}),
+		confx.WithHelpError(),
+	)
+	require.NoError(t, err)
+
+	_, err = loader(context.Background(), "")
+	require.ErrorIs(t, err, pflag.ErrHelp)
+	assert.Contains(t, usage.String(), "--port int   Server port (default 8080)")
+}
+
+func TestHermeticLoader(t *testing.T) {
+	type Config struct {
+		Name string   `confx:"name" validate:"required"`
+		Port int      `confx:"port"`
+		Tags []string `confx:"tags"`
+	}
+
+	fsys := fstest.MapFS{
+		"config/a.yaml": {Data: []byte("name: a\nport: 1000\n")},
+		"config/b.json": {Data: []byte(`{"name": "b", "port": 2000}`)},
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		confPath string
+		expected Config
+	}{
+		{
+			name:     "file only",
+			confPath: "config/a.yaml",
+			expected: Config{Name: "a", Port: 1000, Tags: []string{"default"}},
+		},
+		{
+			name:     "config flag",
+			args:     []string{"--config", "config/b.json"},
+			expected: Config{Name: "b", Port: 2000, Tags: []string{"default"}},
+		},
+		{
+			name:     "env over file",
+			env:      map[string]string{"PORT": "3000", "TAGS": "x,y"},
+			confPath: "config/a.yaml",
+			expected: Config{Name: "a", Port: 3000, Tags: []string{"x", "y"}},
+		},
+		{
+			name:     "flag over env",
+			args:     []string{"--port", "4000"},
+			env:      map[string]string{"NAME": "env", "PORT": "3000"},
+			expected: Config{Name: "env", Port: 4000, Tags: []string{"default"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			loader, err := confx.Initialize(Config{Tags: []string{"default"}},
+				confx.WithViper(viper.New()),
+				confx.WithArgs(tt.args),
+				confx.WithLookupEnv(func(key string) (string, bool) {
+					val, ok := tt.env[key]
+					return val, ok
+				}),
+				confx.WithFS(fsys),
+			)
+			require.NoError(t, err)
+
+			var report confx.LoadReport
+			conf, err := loader(confx.WithLoadReport(context.Background(), &report), tt.confPath)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, conf)
+			for key := range tt.env {
+				if report.Provenance[strings.ToLower(key)].Source != confx.SourceFlag {
+					assert.Equal(t, confx.Origin{Source: confx.SourceEnv, Name: key}, report.Provenance[strings.ToLower(key)].Origin)
+				}
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Parallel()
+
+		loader, err := confx.Initialize(Config{Name: "x"},
+			confx.WithFlagSet(pflag.NewFlagSet("missing", pflag.ContinueOnError)),
+			confx.WithViper(viper.New()),
+			confx.WithArgs(nil),
+			confx.WithFS(fsys),
+		)
+		require.NoError(t, err)
+
+		_, err = loader(context.Background(), "config/missing.yaml")
+		require.ErrorContains(t, err, `failed to read config "config/missing.yaml"`)
+	})
+}
+
+func TestLookupEnvChanges(t *testing.T) {
+	type Config struct {
+		Port int `confx:"port"`
+	}
+
+	env := map[string]string{"PORT": "9090"}
+	loader, err := confx.Initialize(Config{Port: 8080},
+		confx.WithFlagSet(pflag.NewFlagSet("test_lookup_env_changes", pflag.ContinueOnError)),
+		confx.WithViper(viper.New()),
+		confx.WithArgs(nil),
+		confx.WithLookupEnv(func(key string) (string, bool) {
+			val, ok := env[key]
+			return val, ok
+		}),
+	)
+	require.NoError(t, err)
+
+	conf, err := loader(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, 9090, conf.Port)
+
+	// Removing the env var restores the default on the next load.
+	delete(env, "PORT")
+	conf, err = loader(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, 8080, conf.Port)
+}
+
+func TestIsolatedLoaders(t *testing.T) {
+	viper.Reset()
+
+	type ServerConfig struct {
+		Port int `confx:"port"`
+	}
+	type WorkerConfig struct {
+		Port  int `confx:"port"`
+		Count int `confx:"count"`
+	}
+
+	fsys := fstest.MapFS{
+		"server.yaml": {Data: []byte("port: 1000\n")},
+		"worker.yaml": {Data: []byte("port: 2000\ncount: 3\n")},
+	}
+
+	serverLoader, err := confx.Initialize(ServerConfig{Port: 8080},
+		confx.WithFlagSet(pflag.NewFlagSet("server", pflag.ContinueOnError)),
+		confx.WithArgs(nil),
+		confx.WithFS(fsys),
+	)
+	require.NoError(t, err)
+	workerLoader, err := confx.Initialize(WorkerConfig{Port: 9090, Count: 1},
+		confx.WithFlagSet(pflag.NewFlagSet("worker", pflag.ContinueOnError)),
+		confx.WithArgs(nil),
+		confx.WithFS(fsys),
+	)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			conf, err := serverLoader(context.Background(), "server.yaml")
+			assert.NoError(t, err)
+			assert.Equal(t, ServerConfig{Port: 1000}, conf)
+		}()
+		go func() {
+			defer wg.Done()
+			conf, err := workerLoader(context.Background(), "worker.yaml")
+			assert.NoError(t, err)
+			assert.Equal(t, WorkerConfig{Port: 2000, Count: 3}, conf)
+		}()
+	}
+	wg.Wait()
+
+	// The global viper instance is left untouched.
+	assert.False(t, viper.IsSet("port"))
+	assert.Empty(t, viper.ConfigFileUsed())
+}
@@ -2,10 +2,9 @@ package confx
 
 import (
 	"encoding/base64"
-	"encoding/json"
 	"io"
+	"os"
 	"reflect"
-	"strconv"
 	"strings"
 	"time"
 
@@ -16,17 +15,21 @@ import (
 
 var DecoderConfigOption = func(tagName string) func(dc *mapstructure.DecoderConfig) {
 	return func(dc *mapstructure.DecoderConfig) {
-		dc.DecodeHook = mapstructure.ComposeDecodeHookFunc(
-			mapstructure.StringToTimeDurationHookFunc(),
+		if tagName == "" {
+			tagName = DefaultTagName
+		}
+		hook := mapstructure.ComposeDecodeHookFunc(
+			TextUnmarshalerHookFunc(),
+			StringToDurationHookFunc(),
 			mapstructure.StringToTimeHookFunc(time.RFC3339),
 			StringToSliceHookFunc(","),
 			StringToMapHookFunc(",", "="),
 		)
-		if tagName != "" {
-			dc.TagName = tagName
-		} else {
-			dc.TagName = DefaultTagName
-		}
+		dc.DecodeHook = mapstructure.ComposeDecodeHookFunc(
+			secretHookFunc(tagName, hook),
+			hook,
+		)
+		dc.TagName = tagName
 	}
 }
 
@@ -43,6 +46,20 @@ func ReadWithTagName[T any](tagName string, typ string, r io.Reader) (T, error)
 		return zero, errors.Wrap(err, "failed to read config")
 	}
 
+	// References to other keys can only refer to keys of the same document, since the
+	// values read here are taken as is once they are passed to Initialize as defaults.
+	settings := viperInstance.AllSettings()
+	if err := interpolateSettings(settings, os.LookupEnv, isKeyReference); err != nil {
+		return zero, errors.Wrap(err, "failed to interpolate config")
+	}
+	if err := resolveKeyReferences(nil, settings); err != nil {
+		return zero, errors.Wrap(err, "failed to resolve config references")
+	}
+	viperInstance = viper.New()
+	if err := viperInstance.MergeConfigMap(settings); err != nil {
+		return zero, errors.Wrap(err, "failed to read config")
+	}
+
 	var def T
 	if err := viperInstance.Unmarshal(&def, DecoderConfigOption(tagName)); err != nil {
 		return zero, errors.Wrap(err, "failed to unmarshal config")
@@ -53,15 +70,10 @@ func ReadWithTagName[T any](tagName string, typ string, r io.Reader) (T, error)
 
 func StringToSliceHookFunc(separator string) mapstructure.DecodeHookFunc {
 	return func(from reflect.Type, to reflect.Type, data any) (any, error) {
-		if from.Kind() == reflect.String && to.Kind() == reflect.Slice {
+		if from.Kind() == reflect.String && (to.Kind() == reflect.Slice || to.Kind() == reflect.Array) {
 			elemType := to.Elem()
-			if unwrapType(elemType).Kind() == reflect.Struct {
-				sliceValue := reflect.New(to).Elem()
-				err := json.Unmarshal([]byte(data.(string)), sliceValue.Addr().Interface())
-				if err != nil {
-					return nil, errors.Wrapf(err, "failed to unmarshal json, data: %s", data)
-				}
-				return sliceValue.Interface(), nil
+			if isNestedType(to) {
+				return decodeJSON(to, data.(string))
 			}
 			str := strings.Trim(data.(string), "[]")
 			if to == reflect.TypeOf([]byte{}) {
@@ -69,57 +81,48 @@ func StringToSliceHookFunc(separator string) mapstructure.DecodeHookFunc {
 			}
 
 			parts := strings.Split(str, separator)
-
-			switch elemType.Kind() {
-			case reflect.Bool:
-				return parseSlice(parts, strconv.ParseBool)
-			case reflect.Float32, reflect.Float64:
-				return parseSlice(parts, func(s string) (float64, error) {
-					return strconv.ParseFloat(s, 64)
-				})
-			case reflect.Int, reflect.Int32, reflect.Int64:
-				if elemType == typeDuration {
-					// nolint:gocritic
-					return parseSlice(parts, func(s string) (time.Duration, error) {
-						return time.ParseDuration(s)
-					})
+			if to.Kind() == reflect.Array {
+				// The parts are decoded into the array as a slice.
+				if len(parts) > to.Len() {
+					return nil, errors.Errorf("too many values for %s: %d", to, len(parts))
 				}
-				return parseSlice(parts, func(s string) (int64, error) {
-					return strconv.ParseInt(s, 10, 64)
-				})
-			case reflect.String:
+				to = reflect.SliceOf(elemType)
+			}
+			if elemType.Kind() == reflect.String && !isTextType(elemType) {
 				return parts, nil
-			case reflect.Uint, reflect.Uint32, reflect.Uint64:
-				return parseSlice(parts, func(s string) (uint64, error) {
-					return strconv.ParseUint(s, 10, 64)
-				})
-			default:
+			}
+			if !isScalarType(elemType) {
 				return nil, errors.Errorf("unsupported slice element type %q", elemType)
 			}
+			return parseScalarSlice(to, parts)
 		}
 		return data, nil
 	}
 }
 
-func parseSlice[T any](parts []string, parseFunc func(string) (T, error)) ([]T, error) {
+// parseScalarSlice parses parts into a slice of type to, whose element type is a scalar type.
+func parseScalarSlice(to reflect.Type, parts []string) (any, error) {
+	result := reflect.MakeSlice(to, 0, len(parts))
 	if len(parts) == 0 ||
 		(len(parts) == 1 && strings.TrimSpace(parts[0]) == "") {
-		return []T{}, nil
+		return result.Interface(), nil
 	}
-	result := make([]T, len(parts))
-	for i, part := range parts {
-		parsed, err := parseFunc(strings.TrimSpace(part))
+	for _, part := range parts {
+		parsed, err := parseScalar(to.Elem(), strings.TrimSpace(part))
 		if err != nil {
 			return nil, errors.Wrapf(err, "invalid value %q", part)
 		}
-		result[i] = parsed
+		result = reflect.Append(result, parsed)
 	}
-	return result, nil
+	return result.Interface(), nil
 }
 
 func StringToMapHookFunc(separator string, pairSeparator string) mapstructure.DecodeHookFunc {
 	return func(from reflect.Type, to reflect.Type, data any) (any, error) {
 		if from.Kind() == reflect.String && to.Kind() == reflect.Map {
+			if isNestedType(to) {
+				return decodeJSON(to, data.(string))
+			}
 			str := strings.Trim(data.(string), "[]{}")
 			if str == "" {
 				return reflect.MakeMap(to).Interface(), nil
@@ -132,6 +135,9 @@ func StringToMapHookFunc(separator string, pairSeparator string) mapstructure.De
 			if keyType.Kind() != reflect.String {
 				return nil, errors.Errorf("only string keys are supported for map type, got %q", keyType)
 			}
+			if !isScalarType(elemType) {
+				return nil, errors.Errorf("unsupported map value type %q", elemType)
+			}
 
 			result := reflect.MakeMap(to)
 			for _, pair := range pairs {
@@ -143,25 +149,15 @@ func StringToMapHookFunc(separator string, pairSeparator string) mapstructure.De
 				key := kv[0]
 				valueStr := kv[1]
 
-				var value any
-				var err error
-
-				switch elemType.Kind() {
-				case reflect.Int:
-					value, err = strconv.Atoi(strings.TrimSpace(valueStr))
-				case reflect.Int64:
-					value, err = strconv.ParseInt(strings.TrimSpace(valueStr), 10, 64)
-				case reflect.String:
-					value = valueStr
-				default:
-					return nil, errors.Errorf("unsupported map value type %q", elemType)
-				}
-
-				if err != nil {
-					return nil, errors.Wrapf(err, "invalid value %q for key %q", valueStr, key)
+				value := reflect.ValueOf(valueStr)
+				if elemType.Kind() != reflect.String || isTextType(elemType) {
+					var err error
+					if value, err = parseScalar(elemType, strings.TrimSpace(valueStr)); err != nil {
+						return nil, errors.Wrapf(err, "invalid value %q for key %q", valueStr, key)
+					}
 				}
 
-				result.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+				result.SetMapIndex(reflect.ValueOf(key).Convert(keyType), value.Convert(elemType))
 			}
 
 			return result.Interface(), nil
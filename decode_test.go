@@ -130,11 +130,11 @@ func TestStringToSliceHookFunc(t *testing.T) {
 			wantErr:  "",
 		},
 		{
-			name:     "invalid int8 slice",
-			input:    "1,2,3",
-			to:       []int8{}, // because flag set does not support []int8 , keep consistent
+			name:     "out of range int8 in slice",
+			input:    "1,200,3",
+			to:       []int8{},
 			expected: nil,
-			wantErr:  `unsupported slice element type "int8"`,
+			wantErr:  `invalid value "200"`,
 		},
 		{
 			name:     "invalid uint8 slice",
@@ -164,6 +164,41 @@ func TestStringToSliceHookFunc(t *testing.T) {
 			expected: nil,
 			wantErr:  `invalid value "abc"`,
 		},
+		{
+			name:     "int array",
+			input:    "1,2",
+			to:       [3]int{},
+			expected: [3]int{1, 2, 0},
+			wantErr:  "",
+		},
+		{
+			name:     "string array",
+			input:    "a,b,c",
+			to:       [3]string{},
+			expected: [3]string{"a", "b", "c"},
+			wantErr:  "",
+		},
+		{
+			name:     "too many values for array",
+			input:    "1,2,3",
+			to:       [2]int{},
+			expected: nil,
+			wantErr:  "too many values for [2]int: 3",
+		},
+		{
+			name:     "nested int slice as json",
+			input:    "[[1,2],[3]]",
+			to:       [][]int{},
+			expected: [][]int{{1, 2}, {3}},
+			wantErr:  "",
+		},
+		{
+			name:     "array of string slices as json",
+			input:    `[["a"],["b","c"]]`,
+			to:       [2][]string{},
+			expected: [2][]string{{"a"}, {"b", "c"}},
+			wantErr:  "",
+		},
 	}
 
 	for _, tt := range tests {
@@ -224,10 +259,31 @@ func TestStringToMapHookFunc(t *testing.T) {
 		},
 		{
 			name:     "invalid string to bool",
-			input:    "a=true,b=false,c=true", // because flag set does not support map[string]bool , keep consistent
+			input:    "a=true,b=maybe",
 			to:       map[string]bool{},
 			expected: nil,
-			wantErr:  `unsupported map value type "bool"`,
+			wantErr:  `invalid value "maybe" for key "b"`,
+		},
+		{
+			name:     "string to string slice as json",
+			input:    `{"a":["x","y"],"b":[]}`,
+			to:       map[string][]string{},
+			expected: map[string][]string{"a": {"x", "y"}, "b": {}},
+			wantErr:  "",
+		},
+		{
+			name:     "invalid json",
+			input:    "a=x",
+			to:       map[string][]string{},
+			expected: nil,
+			wantErr:  "failed to unmarshal json",
+		},
+		{
+			name:     "unsupported map value type",
+			input:    "a=1",
+			to:       map[string]chan int{},
+			expected: nil,
+			wantErr:  `unsupported map value type "chan int"`,
 		},
 		{
 			name:     "invalid float64 key",
@@ -0,0 +1,159 @@
+package confx
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Day is the duration of the d unit of ParseDuration.
+	Day = 24 * time.Hour
+	// Week is the duration of the w unit of ParseDuration.
+	Week = 7 * Day
+)
+
+// ParseDuration parses a duration like time.ParseDuration, with the additional units
+// d for days and w for weeks, e.g. 1d, 2w, 1.5d or 1w2d12h.
+func ParseDuration(s string) (time.Duration, error) {
+	if !strings.ContainsAny(s, "dw") {
+		return time.ParseDuration(s)
+	}
+
+	str := s
+	neg := false
+	if str != "" && (str[0] == '-' || str[0] == '+') {
+		neg = str[0] == '-'
+		str = str[1:]
+	}
+	if str == "" {
+		return 0, errors.Errorf("invalid duration %q", s)
+	}
+	var total time.Duration
+	for str != "" {
+		// Every segment is a number followed by a unit.
+		i := strings.IndexFunc(str, isNotNumberRune)
+		if i <= 0 {
+			return 0, errors.Errorf("invalid duration %q", s)
+		}
+		j := strings.IndexFunc(str[i:], isNumberRune)
+		if j < 0 {
+			j = len(str)
+		} else {
+			j += i
+		}
+		number, unit := str[:i], str[i:j]
+		str = str[j:]
+
+		var d time.Duration
+		switch unit {
+		case "d", "w":
+			f, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, errors.Errorf("invalid duration %q", s)
+			}
+			size := Day
+			if unit == "w" {
+				size = Week
+			}
+			ns := math.Round(f * float64(size))
+			if ns >= math.MaxInt64 {
+				return 0, errors.Errorf("invalid duration %q", s)
+			}
+			d = time.Duration(ns)
+		default:
+			var err error
+			if d, err = time.ParseDuration(number + unit); err != nil {
+				return 0, errors.Errorf("invalid duration %q", s)
+			}
+		}
+		if total > math.MaxInt64-d {
+			return 0, errors.Errorf("invalid duration %q", s)
+		}
+		total += d
+	}
+	if neg {
+		total = -total
+	}
+	return total, nil
+}
+
+func isNumberRune(r rune) bool {
+	return (r >= '0' && r <= '9') || r == '.'
+}
+
+func isNotNumberRune(r rune) bool {
+	return !isNumberRune(r)
+}
+
+// FormatDuration formats d like time.Duration.String, but with weeks and days and without
+// zero minutes and seconds, e.g. 2w, 1d12h or 1h30m, so that it can be parsed by ParseDuration.
+func FormatDuration(d time.Duration) string {
+	if d == math.MinInt64 {
+		return d.String()
+	}
+	if d < 0 {
+		return "-" + FormatDuration(-d)
+	}
+
+	var b strings.Builder
+	if weeks := d / Week; weeks > 0 {
+		b.WriteString(strconv.FormatInt(int64(weeks), 10) + "w")
+		d -= weeks * Week
+	}
+	if days := d / Day; days > 0 {
+		b.WriteString(strconv.FormatInt(int64(days), 10) + "d")
+		d -= days * Day
+	}
+	if d > 0 || b.Len() == 0 {
+		s := d.String()
+		if strings.HasSuffix(s, "m0s") {
+			s = strings.TrimSuffix(s, "0s")
+		}
+		if strings.HasSuffix(s, "h0m") {
+			s = strings.TrimSuffix(s, "0m")
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+// durationValue is the pflag.Value of a time.Duration field, which accepts the units of ParseDuration.
+type durationValue time.Duration
+
+func (d *durationValue) Set(s string) error {
+	v, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = durationValue(v)
+	return nil
+}
+
+// String returns "0" for the zero duration, so that pflag omits it as a default in help output.
+func (d *durationValue) String() string {
+	if *d == 0 {
+		return "0"
+	}
+	return FormatDuration(time.Duration(*d))
+}
+
+func (d *durationValue) Type() string {
+	return "duration"
+}
+
+// StringToDurationHookFunc returns a DecodeHookFunc that converts strings to time.Duration
+// with ParseDuration.
+func StringToDurationHookFunc() mapstructure.DecodeHookFunc {
+	return func(from reflect.Type, to reflect.Type, data any) (any, error) {
+		if from.Kind() != reflect.String || to != typeDuration {
+			return data, nil
+		}
+		return ParseDuration(reflect.ValueOf(data).String())
+	}
+}
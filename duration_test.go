@@ -0,0 +1,137 @@
+package confx_test
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/qor5/confx"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{input: "0", expected: 0},
+		{input: "90s", expected: 90 * time.Second},
+		{input: "1h30m", expected: 90 * time.Minute},
+		{input: "1d", expected: 24 * time.Hour},
+		{input: "2w", expected: 14 * 24 * time.Hour},
+		{input: "1.5d", expected: 36 * time.Hour},
+		{input: "1w2d12h", expected: (9*24 + 12) * time.Hour},
+		{input: "1d500ms", expected: 24*time.Hour + 500*time.Millisecond},
+		{input: "-1d", expected: -24 * time.Hour},
+		{input: "+3d", expected: 72 * time.Hour},
+		{input: "", wantErr: true},
+		{input: "d", wantErr: true},
+		{input: "1", wantErr: true},
+		{input: "1dd", wantErr: true},
+		{input: "1d2", wantErr: true},
+		{input: "1x", wantErr: true},
+		{input: "100000w", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			d, err := confx.ParseDuration(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, d)
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{d: 0, expected: "0s"},
+		{d: 500 * time.Millisecond, expected: "500ms"},
+		{d: 90 * time.Second, expected: "1m30s"},
+		{d: 2 * time.Minute, expected: "2m"},
+		{d: time.Hour, expected: "1h"},
+		{d: 90 * time.Minute, expected: "1h30m"},
+		{d: confx.Day, expected: "1d"},
+		{d: 36 * time.Hour, expected: "1d12h"},
+		{d: 2 * confx.Week, expected: "2w"},
+		{d: confx.Week + confx.Day + time.Second, expected: "1w1d1s"},
+		{d: -confx.Day, expected: "-1d"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, confx.FormatDuration(tt.d))
+		parsed, err := confx.ParseDuration(tt.expected)
+		require.NoError(t, err)
+		assert.Equal(t, tt.d, parsed)
+	}
+}
+
+func TestExtendedDurationFields(t *testing.T) {
+	type Config struct {
+		Retention time.Duration               `confx:"retention" usage:"Retention window"`
+		Timeout   time.Duration               `confx:"timeout"`
+		Backoff   []time.Duration             `confx:"backoff"`
+		TTL       confx.Secret[time.Duration] `confx:"ttl"`
+	}
+	def := Config{
+		Retention: 2 * confx.Week,
+		Backoff:   []time.Duration{time.Second, time.Minute},
+	}
+
+	fsys := fstest.MapFS{
+		"config.yaml": {Data: []byte(`retention: 30d
+timeout: 1m30s
+backoff: [1s, 1h, 1d]
+ttl: 1w
+`)},
+	}
+	load := func(args []string, env map[string]string, path string) (Config, error) {
+		loader, err := confx.Initialize(def,
+			confx.WithArgs(args),
+			confx.WithFS(fsys),
+			confx.WithLookupEnv(func(key string) (string, bool) {
+				val, ok := env[key]
+				return val, ok
+			}),
+		)
+		require.NoError(t, err)
+		return loader(t.Context(), path)
+	}
+
+	conf, err := load(nil, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, def.Retention, conf.Retention)
+	assert.Equal(t, def.Backoff, conf.Backoff)
+
+	conf, err = load(nil, nil, "config.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, 30*confx.Day, conf.Retention)
+	assert.Equal(t, 90*time.Second, conf.Timeout)
+	assert.Equal(t, []time.Duration{time.Second, time.Hour, confx.Day}, conf.Backoff)
+	assert.Equal(t, confx.Week, conf.TTL.Value())
+
+	conf, err = load([]string{"--retention", "1w", "--backoff", "2d,3d"}, map[string]string{"TIMEOUT": "1d", "TTL": "12h"}, "config.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, confx.Week, conf.Retention)
+	assert.Equal(t, confx.Day, conf.Timeout)
+	assert.Equal(t, []time.Duration{2 * confx.Day, 3 * confx.Day}, conf.Backoff)
+	assert.Equal(t, 12*time.Hour, conf.TTL.Value())
+
+	_, err = load([]string{"--retention", "forever"}, nil, "")
+	assert.ErrorContains(t, err, `invalid argument "forever" for "--retention" flag`)
+
+	flagSet := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	_, err = confx.Initialize(def, confx.WithFlagSet(flagSet))
+	require.NoError(t, err)
+	usage := flagSet.FlagUsages()
+	assert.Regexp(t, `--retention duration\s+Retention window \(default 2w\)`, usage)
+	assert.Regexp(t, `--backoff strings\s+backoff \(default \[1s,1m\]\)`, usage)
+	assert.NotContains(t, usage, "timeout (default")
+}
@@ -0,0 +1,305 @@
+package confx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"filippo.io/age"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// AgeKeyEnv is the env var that holds the age identities used to decrypt ENC[age,...] values.
+	AgeKeyEnv = "CONFX_AGE_KEY"
+	// AgeKeyFileEnv is the env var that holds the path of a file with the age identities used
+	// to decrypt ENC[age,...] values.
+	AgeKeyFileEnv = "CONFX_AGE_KEY_FILE"
+
+	encPrefix = "ENC[age,"
+	encSuffix = "]"
+)
+
+// EncryptValue encrypts plaintext to the age recipients (age1...) and returns it in the
+// ENC[age,<base64>] form that Initialize decrypts at load time.
+func EncryptValue(plaintext string, recipients ...string) (string, error) {
+	if len(recipients) == 0 {
+		return "", errors.New("no age recipients")
+	}
+	rs := make([]age.Recipient, 0, len(recipients))
+	for _, recipient := range recipients {
+		r, err := age.ParseX25519Recipient(strings.TrimSpace(recipient))
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid age recipient %q", recipient)
+		}
+		rs = append(rs, r)
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, rs...)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encrypt")
+	}
+	if _, err := io.WriteString(w, plaintext); err != nil {
+		return "", errors.Wrap(err, "failed to encrypt")
+	}
+	if err := w.Close(); err != nil {
+		return "", errors.Wrap(err, "failed to encrypt")
+	}
+	return encPrefix + base64.StdEncoding.EncodeToString(buf.Bytes()) + encSuffix, nil
+}
+
+// EncryptConfigKey encrypts the value of the dotted key in the YAML or JSON config file at path
+// to the age recipients, in place. Only the value is rewritten, so comments, key order and
+// formatting of the rest of the file are kept. Keys are matched case-insensitively, as viper does.
+// The value must be a single-line scalar that is not already encrypted.
+func EncryptConfigKey(path, key string, recipients ...string) error {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml", ".json":
+	default:
+		return errors.Errorf("cannot encrypt keys of %q: unsupported config type %q", path, strings.TrimPrefix(ext, "."))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read config %q", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read config %q", path)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return errors.Wrapf(err, "failed to read config %q", path)
+	}
+	node := findYAMLKey(&doc, key)
+	if node == nil {
+		return errors.Errorf("key %q not found in %q", key, path)
+	}
+	if node.Kind != yaml.ScalarNode {
+		return errors.Errorf("key %q: only scalar values can be encrypted", key)
+	}
+	if isEncryptedValue(node.Value) {
+		return errors.Errorf("key %q is already encrypted", key)
+	}
+	start, end, err := scalarExtent(data, node)
+	if err != nil {
+		return errors.Wrapf(err, "key %q", key)
+	}
+
+	enc, err := EncryptValue(node.Value, recipients...)
+	if err != nil {
+		return errors.Wrapf(err, "key %q", key)
+	}
+	out := make([]byte, 0, len(data)-(end-start)+len(enc)+2)
+	out = append(out, data[:start]...)
+	out = append(out, '"')
+	out = append(out, enc...)
+	out = append(out, '"')
+	out = append(out, data[end:]...)
+	if err := os.WriteFile(path, out, info.Mode().Perm()); err != nil {
+		return errors.Wrapf(err, "failed to write config %q", path)
+	}
+	return nil
+}
+
+// findYAMLKey returns the value node of the dotted key in doc, or nil if there is none.
+func findYAMLKey(doc *yaml.Node, key string) *yaml.Node {
+	node := doc
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+	for _, part := range strings.Split(key, ".") {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if strings.EqualFold(node.Content[i].Value, part) {
+				next = node.Content[i+1]
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// scalarExtent returns the byte offsets of the source text of the scalar node in data.
+func scalarExtent(data []byte, node *yaml.Node) (int, int, error) {
+	start := 0
+	for line := 1; line < node.Line; line++ {
+		i := bytes.IndexByte(data[start:], '\n')
+		if i < 0 {
+			return 0, 0, errors.New("value not found")
+		}
+		start += i + 1
+	}
+	for col := 1; col < node.Column && start < len(data); col++ {
+		_, size := utf8.DecodeRune(data[start:])
+		start += size
+	}
+
+	rest := data[start:]
+	if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+		rest = rest[:i]
+	}
+	end := -1
+	switch node.Style {
+	case yaml.DoubleQuotedStyle:
+		for i := 1; i < len(rest); i++ {
+			if rest[i] == '\\' {
+				i++
+				continue
+			}
+			if rest[i] == '"' {
+				end = i + 1
+				break
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		for i := 1; i < len(rest); i++ {
+			if rest[i] != '\'' {
+				continue
+			}
+			if i+1 < len(rest) && rest[i+1] == '\'' {
+				i++
+				continue
+			}
+			end = i + 1
+			break
+		}
+	case 0, yaml.TaggedStyle:
+		if bytes.HasPrefix(rest, []byte(node.Value)) {
+			end = len(node.Value)
+		}
+	}
+	if end < 0 {
+		return 0, 0, errors.New("only single-line values can be encrypted")
+	}
+	return start, start + end, nil
+}
+
+// isEncryptedValue reports whether s has the ENC[age,...] form.
+func isEncryptedValue(s string) bool {
+	return strings.HasPrefix(s, encPrefix) && strings.HasSuffix(s, encSuffix)
+}
+
+// ageIdentities returns a function that loads the age identities from the AgeKeyEnv env var,
+// the file named by the AgeKeyFileEnv env var and the key file set by WithAgeKeyFile, on first use.
+func ageIdentities(lookupEnv func(string) (string, bool), keyFile string) func() ([]age.Identity, error) {
+	var identities []age.Identity
+	var loaded bool
+	return func() ([]age.Identity, error) {
+		if loaded {
+			return identities, nil
+		}
+		var loadedIDs []age.Identity
+		if key, ok := lookupEnv(AgeKeyEnv); ok && key != "" {
+			ids, err := age.ParseIdentities(strings.NewReader(key))
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid age identities in %s", AgeKeyEnv)
+			}
+			loadedIDs = append(loadedIDs, ids...)
+		}
+		var paths []string
+		if path, ok := lookupEnv(AgeKeyFileEnv); ok && path != "" {
+			paths = append(paths, path)
+		}
+		if keyFile != "" {
+			paths = append(paths, keyFile)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to read age key file")
+			}
+			ids, err := age.ParseIdentities(bytes.NewReader(data))
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid age identities in %q", path)
+			}
+			loadedIDs = append(loadedIDs, ids...)
+		}
+		if len(loadedIDs) == 0 {
+			return nil, errors.Errorf("no age identities, set %s or %s", AgeKeyEnv, AgeKeyFileEnv)
+		}
+		identities, loaded = loadedIDs, true
+		return identities, nil
+	}
+}
+
+// decryptAge decrypts the ENC[age,...] value s with identities.
+func decryptAge(s string, identities []age.Identity) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(s, encPrefix), encSuffix))
+	if err != nil {
+		return "", errors.New("failed to decrypt: invalid base64")
+	}
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), identities...)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to decrypt")
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to decrypt")
+	}
+	return string(plaintext), nil
+}
+
+// decryptSettings replaces the ENC[age,...] string values of settings with their plaintext, in place,
+// escaped for resolveKeyReferences. Errors name the key of the value and never include the plaintext.
+func decryptSettings(settings map[string]any, identities func() ([]age.Identity, error)) error {
+	var decryptValue func(val any, key string) (any, error)
+	decryptValue = func(val any, key string) (any, error) {
+		switch v := val.(type) {
+		case string:
+			if !isEncryptedValue(v) {
+				return v, nil
+			}
+			ids, err := identities()
+			if err != nil {
+				return nil, errors.Wrapf(err, "key %q", key)
+			}
+			plaintext, err := decryptAge(v, ids)
+			if err != nil {
+				return nil, errors.Wrapf(err, "key %q", key)
+			}
+			return escapeReferences(plaintext), nil
+		case map[string]any:
+			for _, k := range sortedKeys(v) {
+				decrypted, err := decryptValue(v[k], key+"."+k)
+				if err != nil {
+					return nil, err
+				}
+				v[k] = decrypted
+			}
+		case []any:
+			for i := range v {
+				decrypted, err := decryptValue(v[i], fmt.Sprintf("%s[%d]", key, i))
+				if err != nil {
+					return nil, err
+				}
+				v[i] = decrypted
+			}
+		}
+		return val, nil
+	}
+	for _, key := range sortedKeys(settings) {
+		decrypted, err := decryptValue(settings[key], key)
+		if err != nil {
+			return err
+		}
+		settings[key] = decrypted
+	}
+	return nil
+}
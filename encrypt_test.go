@@ -0,0 +1,195 @@
+package confx_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/qor5/confx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptedValues(t *testing.T) {
+	type DatabaseConfig struct {
+		User     string               `confx:"user"`
+		Password confx.Secret[string] `confx:"password"`
+		Port     int                  `confx:"port"`
+	}
+	type Config struct {
+		Database DatabaseConfig `confx:"database"`
+		Tokens   []string       `confx:"tokens"`
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	recipient := identity.Recipient().String()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`# database settings
+database:
+  user: admin # the user
+  password: 's3cret'
+  port: 5432
+tokens:
+  - plain
+`), 0o640))
+
+	require.NoError(t, confx.EncryptConfigKey(path, "database.password", recipient))
+	require.NoError(t, confx.EncryptConfigKey(path, "Database.Port", recipient))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cret")
+	assert.NotContains(t, string(data), "5432")
+	assert.Contains(t, string(data), "# database settings\ndatabase:\n  user: admin # the user\n  password: \"ENC[age,")
+	assert.Contains(t, string(data), "tokens:\n  - plain\n")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+
+	token, err := confx.EncryptValue("t0ken", recipient)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "ENC[age,"))
+
+	newLoader := func(options ...confx.Option) confx.Loader[Config] {
+		loader, err := confx.Initialize(Config{}, append([]confx.Option{confx.WithArgs(nil)}, options...)...)
+		require.NoError(t, err)
+		return loader
+	}
+
+	t.Run("key from env var", func(t *testing.T) {
+		t.Setenv(confx.AgeKeyEnv, identity.String())
+		t.Setenv("TOKENS", token)
+
+		conf, err := newLoader()(t.Context(), path)
+		require.NoError(t, err)
+		assert.Equal(t, "admin", conf.Database.User)
+		assert.Equal(t, "s3cret", conf.Database.Password.Value())
+		assert.Equal(t, 5432, conf.Database.Port)
+		assert.Equal(t, []string{"t0ken"}, conf.Tokens)
+	})
+
+	t.Run("key file", func(t *testing.T) {
+		keyFile := filepath.Join(dir, "key.txt")
+		require.NoError(t, os.WriteFile(keyFile, []byte("# created: now\n"+identity.String()+"\n"), 0o600))
+
+		conf, err := newLoader(confx.WithAgeKeyFile(keyFile))(t.Context(), path)
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", conf.Database.Password.Value())
+
+		t.Setenv(confx.AgeKeyFileEnv, keyFile)
+		conf, err = newLoader()(t.Context(), path)
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", conf.Database.Password.Value())
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		other, err := age.GenerateX25519Identity()
+		require.NoError(t, err)
+		t.Setenv(confx.AgeKeyEnv, other.String())
+
+		_, err = newLoader()(t.Context(), path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `key "database.password": failed to decrypt`)
+		assert.NotContains(t, err.Error(), "s3cret")
+	})
+
+	t.Run("no key", func(t *testing.T) {
+		_, err := newLoader()(t.Context(), path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `key "database.password": no age identities`)
+	})
+
+	t.Run("referenced by other keys", func(t *testing.T) {
+		literal, err := confx.EncryptValue("a${b.c}", recipient)
+		require.NoError(t, err)
+		refs := filepath.Join(dir, "refs.yaml")
+		require.NoError(t, os.WriteFile(refs, []byte(`database:
+  user: "`+literal+`"
+  password: "`+token+`"
+tokens:
+  - postgres://admin:${database.password}@db/app
+  - ${database.user}
+`), 0o600))
+
+		conf, err := newLoader(confx.WithLookupEnv(func(key string) (string, bool) {
+			if key == confx.AgeKeyEnv {
+				return identity.String(), true
+			}
+			return "", false
+		}))(t.Context(), refs)
+		require.NoError(t, err)
+		assert.Equal(t, "t0ken", conf.Database.Password.Value())
+		// Decrypted values are taken literally, even if they look like references.
+		assert.Equal(t, "a${b.c}", conf.Database.User)
+		assert.Equal(t, []string{"postgres://admin:t0ken@db/app", "a${b.c}"}, conf.Tokens)
+	})
+
+	t.Run("corrupted value", func(t *testing.T) {
+		t.Setenv(confx.AgeKeyEnv, identity.String())
+		corrupted := filepath.Join(dir, "corrupted.yaml")
+		require.NoError(t, os.WriteFile(corrupted, []byte("database:\n  password: ENC[age,bm90IGFnZQ==]\n"), 0o600))
+
+		_, err := newLoader()(t.Context(), corrupted)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `key "database.password": failed to decrypt`)
+	})
+}
+
+func TestEncryptConfigKey(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	recipient := identity.Recipient().String()
+	dir := t.TempDir()
+
+	t.Run("json", func(t *testing.T) {
+		path := filepath.Join(dir, "config.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{
+  "database": {"password": "s3cret", "port": 5432},
+  "name": "app"
+}
+`), 0o600))
+
+		require.NoError(t, confx.EncryptConfigKey(path, "database.password", recipient))
+		require.NoError(t, confx.EncryptConfigKey(path, "database.port", recipient))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "s3cret")
+		assert.Regexp(t, `^\{\n  "database": \{"password": "ENC\[age,[^"]+\]", "port": "ENC\[age,[^"]+\]"\},\n  "name": "app"\n\}\n$`, string(data))
+
+		t.Setenv(confx.AgeKeyEnv, identity.String())
+		type Config struct {
+			Database struct {
+				Password string `confx:"password"`
+				Port     int    `confx:"port"`
+			} `confx:"database"`
+		}
+		loader, err := confx.Initialize(Config{}, confx.WithArgs(nil))
+		require.NoError(t, err)
+		conf, err := loader(t.Context(), path)
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", conf.Database.Password)
+		assert.Equal(t, 5432, conf.Database.Port)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		path := filepath.Join(dir, "errors.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("a:\n  b: x\n  c: y\nlist: [1, 2]\ntext: |\n  line\n"), 0o600))
+
+		require.NoError(t, confx.EncryptConfigKey(path, "a.b", recipient))
+		assert.ErrorContains(t, confx.EncryptConfigKey(path, "a.b", recipient), `key "a.b" is already encrypted`)
+		assert.ErrorContains(t, confx.EncryptConfigKey(path, "a.d", recipient), `key "a.d" not found`)
+		assert.ErrorContains(t, confx.EncryptConfigKey(path, "list", recipient), "only scalar values")
+		assert.ErrorContains(t, confx.EncryptConfigKey(path, "text", recipient), "only single-line values")
+		assert.ErrorContains(t, confx.EncryptConfigKey(path, "a.c", "age1invalid"), "invalid age recipient")
+
+		toml := filepath.Join(dir, "config.toml")
+		require.NoError(t, os.WriteFile(toml, []byte("a = 1\n"), 0o600))
+		assert.ErrorContains(t, confx.EncryptConfigKey(toml, "a", recipient), `unsupported config type "toml"`)
+	})
+}
@@ -0,0 +1,75 @@
+package confx
+
+import (
+	"bytes"
+	"io/fs"
+
+	"github.com/pkg/errors"
+	"github.com/subosito/gotenv"
+)
+
+// envFileFlagName is the flag added by WithEnvFile.
+const envFileFlagName = "env-file"
+
+// envFile is the content of a single dotenv file.
+type envFile struct {
+	path   string
+	values map[string]string
+}
+
+// readEnvFiles reads the dotenv files passed via --env-file, or else the ones set by WithEnvFile.
+// The files set by WithEnvFile are optional, while the ones passed via the flag must exist.
+func readEnvFiles(opts *initOptions) ([]*envFile, error) {
+	paths, optional := opts.envFiles, true
+	if flag := opts.flagSet.Lookup(envFileFlagName); flag != nil && flag.Changed {
+		var err error
+		if paths, err = opts.flagSet.GetStringSlice(envFileFlagName); err != nil {
+			return nil, errors.Wrapf(err, "failed to get flag %q", envFileFlagName)
+		}
+		optional = false
+	}
+
+	var files []*envFile
+	for _, path := range paths {
+		data, err := readFile(opts, path)
+		if err != nil {
+			if optional && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "failed to read env file %q", path)
+		}
+		values, err := gotenv.StrictParse(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse env file %q", path)
+		}
+		files = append(files, &envFile{path: path, values: values})
+	}
+	return files, nil
+}
+
+// lookupEnvFiles returns the value of key in files, later files taking precedence.
+func lookupEnvFiles(files []*envFile, key string) (*envFile, string, bool) {
+	for i := len(files) - 1; i >= 0; i-- {
+		if val, ok := files[i].values[key]; ok {
+			return files[i], val, true
+		}
+	}
+	return nil, "", false
+}
+
+// withEnvFiles returns a lookup function that falls back to files for env vars
+// that are unset or empty in lookupEnv.
+func withEnvFiles(lookupEnv func(string) (string, bool), files []*envFile) func(string) (string, bool) {
+	if len(files) == 0 {
+		return lookupEnv
+	}
+	return func(key string) (string, bool) {
+		if val, ok := lookupEnv(key); ok && val != "" {
+			return val, true
+		}
+		if _, val, ok := lookupEnvFiles(files, key); ok {
+			return val, true
+		}
+		return lookupEnv(key)
+	}
+}
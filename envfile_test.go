@@ -0,0 +1,136 @@
+package confx_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/qor5/confx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvFile(t *testing.T) {
+	type Config struct {
+		Host     string   `confx:"host"`
+		Port     int      `confx:"port"`
+		Password string   `confx:"password"`
+		Tags     []string `confx:"tags"`
+	}
+
+	fsys := fstest.MapFS{
+		".env": {Data: []byte(`# local overrides
+export APP_HOST=env-file.example.com
+APP_PORT=1000 # inline comment
+APP_PASSWORD='p@ss # not a comment'
+APP_TAGS="a,b"
+`)},
+		".env.local":  {Data: []byte("APP_PORT=2000\n")},
+		"prod.env":    {Data: []byte("APP_HOST=prod.example.com\n")},
+		"bad.env":     {Data: []byte("not a valid line\n")},
+		"config.yaml": {Data: []byte("host: file.example.com\nport: 3000\n")},
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		files    []string
+		expected Config
+		origins  map[string]confx.Origin
+	}{
+		{
+			name:     "parsing",
+			files:    []string{".env"},
+			expected: Config{Host: "env-file.example.com", Port: 1000, Password: "p@ss # not a comment", Tags: []string{"a", "b"}},
+			origins: map[string]confx.Origin{
+				"host": {Source: confx.SourceEnvFile, Name: ".env"},
+			},
+		},
+		{
+			name:     "later files win and missing files are skipped",
+			files:    []string{".env", ".env.local", ".env.missing"},
+			expected: Config{Host: "env-file.example.com", Port: 2000, Password: "p@ss # not a comment", Tags: []string{"a", "b"}},
+			origins: map[string]confx.Origin{
+				"port": {Source: confx.SourceEnvFile, Name: ".env.local"},
+			},
+		},
+		{
+			name:     "env over env file",
+			files:    []string{".env"},
+			env:      map[string]string{"APP_HOST": "env.example.com", "APP_PORT": ""},
+			expected: Config{Host: "env.example.com", Port: 1000, Password: "p@ss # not a comment", Tags: []string{"a", "b"}},
+			origins: map[string]confx.Origin{
+				"host": {Source: confx.SourceEnv, Name: "APP_HOST"},
+				"port": {Source: confx.SourceEnvFile, Name: ".env"},
+			},
+		},
+		{
+			name:     "flag replaces option",
+			args:     []string{"--env-file", "prod.env"},
+			files:    []string{".env"},
+			expected: Config{Host: "prod.example.com", Port: 3000, Tags: []string{}},
+			origins: map[string]confx.Origin{
+				"host": {Source: confx.SourceEnvFile, Name: "prod.env"},
+				"port": {Source: confx.SourceFile, Name: "config.yaml"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			loader, err := confx.Initialize(Config{},
+				confx.WithEnvPrefix("APP_"),
+				confx.WithArgs(tt.args),
+				confx.WithLookupEnv(func(key string) (string, bool) {
+					val, ok := tt.env[key]
+					return val, ok
+				}),
+				confx.WithFS(fsys),
+				confx.WithEnvFile(tt.files...),
+			)
+			require.NoError(t, err)
+
+			report := &confx.LoadReport{}
+			conf, err := loader(confx.WithLoadReport(context.Background(), report), "config.yaml")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, conf)
+			for key, origin := range tt.origins {
+				assert.Equal(t, origin, report.Provenance[key].Origin, key)
+			}
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		for path, msg := range map[string]string{
+			"bad.env":     `failed to parse env file "bad.env"`,
+			"missing.env": `failed to read env file "missing.env"`,
+		} {
+			loader, err := confx.Initialize(Config{},
+				confx.WithArgs([]string{"--env-file", path}),
+				confx.WithFS(fsys),
+				confx.WithEnvFile(".env"),
+			)
+			require.NoError(t, err)
+
+			_, err = loader(context.Background(), "")
+			require.ErrorContains(t, err, msg)
+		}
+	})
+
+	t.Run("unknown keys", func(t *testing.T) {
+		loader, err := confx.Initialize(Config{},
+			confx.WithEnvPrefix("APP_"),
+			confx.WithArgs(nil),
+			confx.WithFS(fstest.MapFS{".env": {Data: []byte("APP_HOTS=localhost\n")}}),
+			confx.WithEnvFile(".env"),
+			confx.WithUnknownEnvCheck(true),
+		)
+		require.NoError(t, err)
+
+		_, err = loader(context.Background(), "")
+		require.ErrorContains(t, err, `APP_HOTS in .env, did you mean "APP_HOST"?`)
+	})
+}
@@ -21,7 +21,7 @@ type ServerConfig struct {
 type CommonDBConfig struct {
 	Name     string        `confx:"name" usage:"Database name" validate:"required"`
 	Username string        `confx:"username" usage:"Database username"`
-	Password string        `confx:"password" usage:"Database password"`
+	Password string        `confx:"password" usage:"Database password" secret:"true"`
 	Timeout  time.Duration `confx:"timeout" usage:"Database connection timeout" validate:"gte=0"`
 }
 
@@ -37,13 +37,13 @@ type DatabaseConfig struct {
 
 // JWTConfig defines JWT authentication configuration.
 type JWTConfig struct {
-	Secret string `confx:"secret" usage:"JWT secret key" validate:"required"`
+	Secret string `confx:"secret" usage:"JWT secret key" validate:"required" secret:"true"`
 }
 
 // OAuthConfig defines OAuth authentication configuration.
 type OAuthConfig struct {
 	ClientID     string `confx:"clientID" usage:"OAuth client ID" validate:"required"`
-	ClientSecret string `confx:"clientSecret" usage:"OAuth client secret" validate:"required"`
+	ClientSecret string `confx:"clientSecret" usage:"OAuth client secret" validate:"required" secret:"true"`
 }
 
 // AuthConfig defines authentication configuration with skip_nested_unless validation.
@@ -70,16 +70,10 @@ type Config struct {
 	PublicField    string         `confx:"-"` // ignored by confx
 }
 
-// maskSecret masks a secret string for display.
-func maskSecret(secret string) string {
-	if len(secret) <= 4 {
-		return "****"
-	}
-	return secret[:2] + "****" + secret[len(secret)-2:]
-}
-
-// Print prints the configuration.
+// Print prints the configuration with secret fields masked.
 func (c *Config) Print() {
+	c = confx.Redact(c)
+
 	fmt.Println("=== Server Configuration ===")
 	fmt.Printf("Host: %s\n", c.Server.Host)
 	fmt.Printf("Port: %d\n", c.Server.Port)
@@ -98,7 +92,7 @@ func (c *Config) Print() {
 		fmt.Printf("Username: %s\n", c.Database.Username)
 	}
 	if c.Database.Password != "" {
-		fmt.Printf("Password: %s\n", maskSecret(c.Database.Password))
+		fmt.Printf("Password: %s\n", c.Database.Password)
 	}
 	fmt.Printf("Timeout: %s\n", c.Database.Timeout)
 
@@ -106,10 +100,10 @@ func (c *Config) Print() {
 	fmt.Printf("Provider: %s\n", c.Auth.Provider)
 	switch c.Auth.Provider {
 	case "jwt":
-		fmt.Printf("JWT Secret: %s\n", maskSecret(c.Auth.JWT.Secret))
+		fmt.Printf("JWT Secret: %s\n", c.Auth.JWT.Secret)
 	case "oauth":
 		fmt.Printf("OAuth Client ID: %s\n", c.Auth.OAuth.ClientID)
-		fmt.Printf("OAuth Client Secret: %s\n", maskSecret(c.Auth.OAuth.ClientSecret))
+		fmt.Printf("OAuth Client Secret: %s\n", c.Auth.OAuth.ClientSecret)
 	}
 
 	fmt.Println("\n=== Logging Configuration ===")
@@ -123,10 +117,18 @@ func (c *Config) Print() {
 //go:embed embed/default-config.yaml
 var defaultConfigYAML string
 
+// Development defaults of the secret fields, which are not written to the embedded config file.
+const (
+	defaultDatabasePassword = "dbpass"
+	defaultJWTSecret        = "change-me-in-production"
+)
+
 func Initialize(opts ...confx.Option) (confx.Loader[*Config], error) {
 	def, err := confx.Read[*Config]("yaml", strings.NewReader(defaultConfigYAML))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load default config from embedded YAML")
 	}
+	def.Database.Password = defaultDatabasePassword
+	def.Auth.JWT.Secret = defaultJWTSecret
 	return confx.Initialize(def, opts...)
 }
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"flag"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -92,6 +94,34 @@ server:
 	assert.Equal(t, 9090, config.Server.Port)
 }
 
+var update = flag.Bool("update", false, "regenerate embed/default-config.yaml")
+
+// TestDefaultConfigUpToDate ensures the embedded default config stays in sync with the Config struct.
+func TestDefaultConfigUpToDate(t *testing.T) {
+	def, err := confx.Read[*Config]("yaml", strings.NewReader(defaultConfigYAML))
+	require.NoError(t, err)
+
+	sample, err := confx.GenerateSample("yaml", def, confx.WithEnvPrefix("APP_"))
+	require.NoError(t, err)
+
+	if *update {
+		require.NoError(t, os.WriteFile("embed/default-config.yaml", sample, 0o644))
+		return
+	}
+	assert.Equal(t, string(sample), defaultConfigYAML, "embed/default-config.yaml is out of date, run: go test -run TestDefaultConfigUpToDate -update")
+}
+
+// TestInitializeDefaults ensures the secret fields keep their development defaults.
+func TestInitializeDefaults(t *testing.T) {
+	loader, err := Initialize(confx.WithArgs([]string{}), confx.WithLookupEnv(func(string) (string, bool) { return "", false }))
+	require.NoError(t, err)
+	conf, err := loader(t.Context(), "")
+	require.NoError(t, err)
+	assert.Equal(t, "dbpass", conf.Database.Password)
+	assert.Equal(t, "change-me-in-production", conf.Auth.JWT.Secret)
+	assert.Equal(t, "mydb", conf.Database.Name)
+}
+
 // getValidConfig returns a valid configuration for testing.
 func getValidConfig() Config {
 	return Config{
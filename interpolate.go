@@ -0,0 +1,360 @@
+package confx
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+)
+
+// interpolateSettings expands the env var references in the string values of settings in place.
+// If isKeyRef is not nil, the references it accepts are left for resolveKeyReferences, and so
+// are $${ escapes.
+func interpolateSettings(settings map[string]any, lookupEnv func(string) (string, bool), isKeyRef func(string) bool) error {
+	for _, key := range sortedKeys(settings) {
+		val, err := interpolateValue(settings[key], key, lookupEnv, isKeyRef)
+		if err != nil {
+			return err
+		}
+		settings[key] = val
+	}
+	return nil
+}
+
+func interpolateValue(val any, key string, lookupEnv func(string) (string, bool), isKeyRef func(string) bool) (any, error) {
+	switch v := val.(type) {
+	case string:
+		s, err := expandEnv(v, lookupEnv, isKeyRef)
+		if err != nil {
+			return nil, errors.Wrapf(err, "key %q", key)
+		}
+		return s, nil
+	case map[string]any:
+		for _, k := range sortedKeys(v) {
+			expanded, err := interpolateValue(v[k], key+"."+k, lookupEnv, isKeyRef)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = expanded
+		}
+	case []any:
+		for i := range v {
+			expanded, err := interpolateValue(v[i], fmt.Sprintf("%s[%d]", key, i), lookupEnv, isKeyRef)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = expanded
+		}
+	}
+	return val, nil
+}
+
+// expandEnv expands the env var references in s:
+//
+//   - ${VAR} is replaced by the value of VAR, or "" if it is unset.
+//   - ${VAR:-default} is replaced by default if VAR is unset or empty.
+//   - ${VAR:?message} fails with message if VAR is unset or empty.
+//   - $${ is replaced by a literal ${.
+//
+// Defaults may contain references themselves, e.g. ${A:-${B}}.
+// References accepted by isKeyRef and $${ escapes are kept as is when isKeyRef is not nil.
+func expandEnv(s string, lookupEnv func(string) (string, bool), isKeyRef func(string) bool) (string, error) {
+	return replaceReferences(s, isKeyRef != nil, func(ref string) (string, error) {
+		if name, _, _ := splitReference(ref); isKeyRef != nil && isKeyRef(name) {
+			return "${" + ref + "}", nil
+		}
+		return expandReference(ref, "env var", func(name string) (string, error) {
+			val, _ := lookupEnv(name)
+			return val, nil
+		}, func(s string) (string, error) {
+			return expandEnv(s, lookupEnv, isKeyRef)
+		})
+	})
+}
+
+// replaceReferences replaces every ${...} reference in s with the result of fn for its body.
+// $${ escapes are replaced by ${, or kept as is if keepEscapes is true.
+func replaceReferences(s string, keepEscapes bool, fn func(ref string) (string, error)) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var sb strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			sb.WriteString(s)
+			return sb.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			sb.WriteString(s[:i-1])
+			if keepEscapes {
+				sb.WriteString("$")
+			}
+			sb.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		sb.WriteString(s[:i])
+
+		end := matchingBrace(s, i+2)
+		if end < 0 {
+			return "", errors.Errorf("unterminated reference in %q", s[i:])
+		}
+		val, err := fn(s[i+2 : end])
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(val)
+		s = s[end+1:]
+	}
+}
+
+// matchingBrace returns the index of the "}" closing the reference whose body starts at start.
+func matchingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "${"):
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitReference splits the body of a reference into its name, its :- or :? operator and
+// the operator argument.
+func splitReference(ref string) (name, op, arg string) {
+	if i := strings.Index(ref, ":"); i >= 0 && i+1 < len(ref) && (ref[i+1] == '-' || ref[i+1] == '?') {
+		return ref[:i], ref[i : i+2], ref[i+2:]
+	}
+	return ref, "", ""
+}
+
+// expandReference returns the value of the reference body ref, looking its name up with lookup
+// and expanding defaults with expand. kind names what the reference refers to in errors.
+func expandReference(ref, kind string, lookup func(name string) (string, error), expand func(s string) (string, error)) (string, error) {
+	name, op, arg := splitReference(ref)
+	if name == "" || strings.ContainsAny(name, "${}: ") {
+		return "", errors.Errorf("invalid reference ${%s}", ref)
+	}
+
+	val, err := lookup(name)
+	if err != nil || val != "" {
+		return val, err
+	}
+	switch op {
+	case ":-":
+		return expand(arg)
+	case ":?":
+		if arg == "" {
+			return "", errors.Errorf("%s %s is not set", kind, name)
+		}
+		return "", errors.Errorf("%s %s is not set: %s", kind, name, arg)
+	}
+	return "", nil
+}
+
+// escapeReferences escapes the ${ in the resolved secret s as $${, so that resolveKeyReferences
+// keeps it as is.
+func escapeReferences(s string) string {
+	return strings.ReplaceAll(s, "${", "$${")
+}
+
+// escapeSourceValues escapes the references in the values of settings that do not come from
+// config files, i.e. from flags, env vars, env files and defaults, so that only config files
+// can reference other keys and the other values are taken as is.
+func escapeSourceValues(settings map[string]any, provenance map[string]Provenance) {
+	for key, p := range provenance {
+		if p.Source == SourceFile {
+			continue
+		}
+		key = strings.ToLower(key)
+		if val, ok := getSetting(settings, key); ok {
+			setSetting(settings, key, escapeValue(val))
+		}
+	}
+}
+
+// escapeValue returns a copy of val with the references in its strings escaped.
+func escapeValue(val any) any {
+	switch v := val.(type) {
+	case string:
+		return escapeReferences(v)
+	case []any:
+		escaped := make([]any, len(v))
+		for i, elem := range v {
+			escaped[i] = escapeValue(elem)
+		}
+		return escaped
+	case map[string]any:
+		escaped := make(map[string]any, len(v))
+		for k, elem := range v {
+			escaped[k] = escapeValue(elem)
+		}
+		return escaped
+	}
+	return val
+}
+
+// isKeyReference reports whether the reference name refers to a config key rather than
+// an env var, i.e. it contains a dot. Top-level keys are referenced with a leading dot, e.g.
+// ${.publicUrl}, so that env vars named like a key, such as ${HOST} for host, stay env vars.
+func isKeyReference(name string) bool {
+	return strings.Contains(name, ".")
+}
+
+// hasKey reports whether the dotted key is a registered key or a key below a leaf field,
+// such as a map entry.
+func (n *keyNode) hasKey(key string) bool {
+	if n == nil {
+		return false
+	}
+	node := n
+	for _, part := range strings.Split(key, ".") {
+		if node.isLeaf() {
+			return true
+		}
+		if node = node.findChild(part); node == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// keyResolver resolves the references to other config keys in the merged settings.
+type keyResolver struct {
+	root     *keyNode
+	settings map[string]any
+	resolved map[string]any // lowercased key -> resolved value
+	visiting []string
+}
+
+// resolveKeyReferences replaces the ${key} references in the string values of settings with
+// the values of the referenced keys, in place. A value that consists of a single reference
+// takes the type of the referenced value. Cycles and references to unknown keys are errors.
+func resolveKeyReferences(root *keyNode, settings map[string]any) error {
+	r := &keyResolver{root: root, settings: settings, resolved: map[string]any{}}
+	return r.resolveTree(settings, "")
+}
+
+func (r *keyResolver) resolveTree(m map[string]any, prefix string) error {
+	for _, k := range sortedKeys(m) {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if sub, ok := m[k].(map[string]any); ok {
+			if err := r.resolveTree(sub, key); err != nil {
+				return err
+			}
+			continue
+		}
+		val, err := r.resolveKey(key)
+		if err != nil {
+			return errors.Wrapf(err, "key %q", key)
+		}
+		m[k] = val
+	}
+	return nil
+}
+
+func (r *keyResolver) resolveKey(name string) (any, error) {
+	name = strings.TrimPrefix(name, ".")
+	key := strings.ToLower(name)
+	if val, ok := r.resolved[key]; ok {
+		return val, nil
+	}
+	if i := slices.Index(r.visiting, key); i >= 0 {
+		return nil, errors.Errorf("reference cycle %s", strings.Join(append(r.visiting[i:], key), " -> "))
+	}
+	val, ok := getSetting(r.settings, key)
+	if !ok {
+		if r.root.hasKey(key) {
+			return nil, nil // e.g. a missing map entry, which counts as unset
+		}
+		return nil, errors.Errorf("reference to unknown key %q", name)
+	}
+
+	r.visiting = append(r.visiting, key)
+	val, err := r.resolveValue(val)
+	r.visiting = r.visiting[:len(r.visiting)-1]
+	if err != nil {
+		return nil, err
+	}
+	r.resolved[key] = val
+	return val, nil
+}
+
+func (r *keyResolver) resolveValue(val any) (any, error) {
+	switch v := val.(type) {
+	case string:
+		// A single reference keeps the type of the referenced value.
+		if strings.HasPrefix(v, "${") && matchingBrace(v, 2) == len(v)-1 {
+			if ref := v[2 : len(v)-1]; isKeyReference(ref) && !strings.ContainsAny(ref, ":") {
+				return r.resolveKey(ref)
+			}
+		}
+		return r.resolveString(v)
+	case map[string]any:
+		resolved := make(map[string]any, len(v))
+		for k, elem := range v {
+			elem, err := r.resolveValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			resolved[k] = elem
+		}
+		return resolved, nil
+	case []any:
+		resolved := make([]any, len(v))
+		for i, elem := range v {
+			elem, err := r.resolveValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = elem
+		}
+		return resolved, nil
+	}
+	return val, nil
+}
+
+func (r *keyResolver) resolveString(s string) (string, error) {
+	return replaceReferences(s, false, func(ref string) (string, error) {
+		if name, _, _ := splitReference(ref); !isKeyReference(name) {
+			return "${" + ref + "}", nil
+		}
+		return expandReference(strings.TrimPrefix(ref, "."), "key", func(name string) (string, error) {
+			val, err := r.resolveKey(name)
+			if err != nil {
+				return "", err
+			}
+			s, err := cast.ToStringE(val)
+			return s, errors.Wrapf(err, "key %q cannot be used inside a string", name)
+		}, r.resolveString)
+	})
+}
+
+// getSetting returns the value of the dotted key in settings.
+func getSetting(settings map[string]any, key string) (any, bool) {
+	path := strings.Split(key, ".")
+	var val any = settings
+	for _, part := range path {
+		m, ok := val.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if val, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return val, true
+}
@@ -0,0 +1,247 @@
+package confx_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/qor5/confx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadInterpolation(t *testing.T) {
+	type Config struct {
+		Value string `confx:"value"`
+	}
+
+	t.Setenv("CONFX_TEST_HOST", "db.example.com")
+	t.Setenv("CONFX_TEST_EMPTY", "")
+
+	tests := []struct {
+		value    string
+		expected string
+		err      string
+	}{
+		{value: "${CONFX_TEST_HOST}", expected: "db.example.com"},
+		{value: "postgres://${CONFX_TEST_HOST}:5432/app", expected: "postgres://db.example.com:5432/app"},
+		{value: "${CONFX_TEST_UNSET}", expected: ""},
+		{value: "${CONFX_TEST_UNSET:-localhost}", expected: "localhost"},
+		{value: "${CONFX_TEST_EMPTY:-localhost}", expected: "localhost"},
+		{value: "${CONFX_TEST_HOST:-localhost}", expected: "db.example.com"},
+		{value: "${CONFX_TEST_UNSET:-${CONFX_TEST_HOST}}", expected: "db.example.com"},
+		{value: "${CONFX_TEST_HOST:?host is required}", expected: "db.example.com"},
+		{value: "$${CONFX_TEST_HOST}", expected: "${CONFX_TEST_HOST}"},
+		{value: "$CONFX_TEST_HOST", expected: "$CONFX_TEST_HOST"},
+		{value: "${CONFX_TEST_UNSET:?host is required}", err: `key "value": env var CONFX_TEST_UNSET is not set: host is required`},
+		{value: "${CONFX_TEST_EMPTY:?}", err: `key "value": env var CONFX_TEST_EMPTY is not set`},
+		{value: "${CONFX_TEST_HOST", err: `unterminated reference in "${CONFX_TEST_HOST"`},
+		{value: "${}", err: `invalid reference ${}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			conf, err := confx.Read[Config]("yaml", strings.NewReader("value: '"+tt.value+"'\n"))
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, conf.Value)
+		})
+	}
+}
+
+func TestLoaderInterpolation(t *testing.T) {
+	type DatabaseConfig struct {
+		DSN  string `confx:"dsn"`
+		Port int    `confx:"port"`
+	}
+	type Config struct {
+		Database DatabaseConfig `confx:"database"`
+		Hosts    []string       `confx:"hosts"`
+	}
+
+	fsys := fstest.MapFS{
+		"config.yaml":   {Data: []byte("database:\n  dsn: postgres://${DB_HOST}/app\n  port: ${DB_PORT:-5432}\nhosts:\n  - ${HOST_A}\n  - b\n")},
+		"required.json": {Data: []byte(`{"hosts": ["a", "${HOST_B:?must be set}"]}`)},
+		".env":          {Data: []byte("DB_HOST=env-file.example.com\n")},
+	}
+	env := map[string]string{"HOST_A": "a", "DB_PORT": "6543"}
+
+	loader, err := confx.Initialize(Config{},
+		confx.WithArgs(nil),
+		confx.WithLookupEnv(func(key string) (string, bool) {
+			val, ok := env[key]
+			return val, ok
+		}),
+		confx.WithFS(fsys),
+		confx.WithEnvFile(".env"),
+	)
+	require.NoError(t, err)
+
+	conf, err := loader(context.Background(), "config.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, Config{
+		Database: DatabaseConfig{DSN: "postgres://env-file.example.com/app", Port: 6543},
+		Hosts:    []string{"a", "b"},
+	}, conf)
+
+	_, err = loader(context.Background(), "config.yaml,required.json")
+	require.EqualError(t, err, `failed to read config "required.json": key "hosts[1]": env var HOST_B is not set: must be set`)
+}
+
+func TestKeyReferences(t *testing.T) {
+	type ServerConfig struct {
+		Host string `confx:"host"`
+		Port int    `confx:"port"`
+	}
+	type Config struct {
+		Server    ServerConfig      `confx:"server"`
+		PublicURL string            `confx:"publicUrl"`
+		AdminPort int               `confx:"adminPort"`
+		Labels    map[string]string `confx:"labels"`
+		Greeting  string            `confx:"greeting"`
+	}
+
+	fsys := fstest.MapFS{
+		"config.yaml": {Data: []byte(`server:
+  host: ${HOST:-localhost}
+  port: 8080
+publicUrl: https://${server.host}:${server.port}/${labels.env}
+adminPort: ${server.port}
+labels:
+  env: ${ENV}
+  url: ${.publicUrl}
+greeting: $${server.host} is ${HOME_DIR:-unset}
+`)},
+		"cycle.yaml":    {Data: []byte("publicUrl: ${labels.url}\nlabels:\n  url: x${.greeting}\ngreeting: ${.publicUrl}\n")},
+		"dangling.yaml": {Data: []byte("publicUrl: https://${server.hots}\n")},
+		"default.yaml":  {Data: []byte("publicUrl: ${labels.missing:-none} ${.greeting:?greeting is required}\n")},
+	}
+	env := map[string]string{"ENV": "prod", "HOST": "example.com"}
+
+	newLoader := func(args ...string) confx.Loader[Config] {
+		loader, err := confx.Initialize(Config{},
+			confx.WithArgs(args),
+			confx.WithLookupEnv(func(key string) (string, bool) {
+				val, ok := env[key]
+				return val, ok
+			}),
+			confx.WithFS(fsys),
+		)
+		require.NoError(t, err)
+		return loader
+	}
+
+	t.Run("resolved after merging all sources", func(t *testing.T) {
+		conf, err := newLoader("--server-port", "9090")(context.Background(), "config.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, Config{
+			Server:    ServerConfig{Host: "example.com", Port: 9090},
+			PublicURL: "https://example.com:9090/prod",
+			AdminPort: 9090,
+			Labels:    map[string]string{"env": "prod", "url": "https://example.com:9090/prod"},
+			Greeting:  "${server.host} is unset",
+		}, conf)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		_, err := newLoader()(context.Background(), "cycle.yaml")
+		require.EqualError(t, err, `failed to resolve config references: key "greeting": reference cycle greeting -> publicurl -> labels.url -> greeting`)
+	})
+
+	t.Run("dangling", func(t *testing.T) {
+		_, err := newLoader()(context.Background(), "dangling.yaml")
+		require.EqualError(t, err, `failed to resolve config references: key "publicurl": reference to unknown key "server.hots"`)
+	})
+
+	t.Run("env var named like a key", func(t *testing.T) {
+		type Config struct {
+			Host string `confx:"host"`
+		}
+		fsys := fstest.MapFS{"config.yaml": {Data: []byte("host: ${HOST:-x}\n")}}
+		for _, tt := range []struct {
+			env      map[string]string
+			expected string
+		}{
+			{env: map[string]string{"HOST": "envhost"}, expected: "envhost"},
+			{env: map[string]string{}, expected: "x"},
+		} {
+			loader, err := confx.Initialize(Config{},
+				confx.WithArgs([]string{}),
+				confx.WithEnvPrefix("APP_"),
+				confx.WithLookupEnv(func(key string) (string, bool) {
+					val, ok := tt.env[key]
+					return val, ok
+				}),
+				confx.WithFS(fsys),
+			)
+			require.NoError(t, err)
+			conf, err := loader(context.Background(), "config.yaml")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, conf.Host)
+		}
+	})
+
+	t.Run("operators", func(t *testing.T) {
+		_, err := newLoader()(context.Background(), "default.yaml")
+		require.EqualError(t, err, `failed to resolve config references: key "publicurl": key greeting is not set: greeting is required`)
+	})
+	t.Run("other sources taken as is", func(t *testing.T) {
+		type Config struct {
+			Server   ServerConfig `confx:"server"`
+			Password string       `confx:"password"`
+			Token    string       `confx:"token"`
+			Hint     string       `confx:"hint"`
+			Note     string       `confx:"note"`
+			Tags     []string     `confx:"tags"`
+			Link     string       `confx:"link"`
+		}
+		fsys := fstest.MapFS{"config.yaml": {Data: []byte("server:\n  host: example.com\nlink: ${.hint}\n")}}
+		env := map[string]string{"PASSWORD": "ab${cd", "TOKEN": "a$${b}"}
+		loader, err := confx.Initialize(Config{Hint: "see ${server.host}"},
+			confx.WithArgs([]string{"--note", "x${server.host}", "--tags", "x${server.host},${y"}),
+			confx.WithLookupEnv(func(key string) (string, bool) {
+				val, ok := env[key]
+				return val, ok
+			}),
+			confx.WithFS(fsys),
+		)
+		require.NoError(t, err)
+		conf, err := loader(context.Background(), "config.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, Config{
+			Server:   ServerConfig{Host: "example.com"},
+			Password: "ab${cd",
+			Token:    "a$${b}",
+			Hint:     "see ${server.host}",
+			Note:     "x${server.host}",
+			Tags:     []string{"x${server.host}", "${y"},
+			Link:     "see ${server.host}",
+		}, conf)
+	})
+}
+
+func TestReadKeyReferences(t *testing.T) {
+	type ServerConfig struct {
+		Host string `confx:"host"`
+	}
+	type Config struct {
+		Server ServerConfig `confx:"server"`
+		Name   string       `confx:"name"`
+		URL    string       `confx:"url"`
+	}
+
+	conf, err := confx.Read[Config]("yaml", strings.NewReader("server:\n  host: example.com\nname: app\nurl: https://${server.host}/${.name}/$${.name}\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Config{
+		Server: ServerConfig{Host: "example.com"},
+		Name:   "app",
+		URL:    "https://example.com/app/${.name}",
+	}, conf)
+
+	_, err = confx.Read[Config]("yaml", strings.NewReader("url: https://${server.x}\n"))
+	require.EqualError(t, err, `failed to resolve config references: key "url": reference to unknown key "server.x"`)
+}
@@ -0,0 +1,215 @@
+package confx
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/spf13/viper"
+)
+
+// configLayer is the content of a single config file, with keys lowercased as viper does.
+type configLayer struct {
+	path     string
+	settings map[string]any
+}
+
+// splitConfigPaths splits a comma-separated list of config paths, dropping empty entries.
+func splitConfigPaths(s string) []string {
+	var paths []string
+	for _, path := range strings.Split(s, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// readConfigFiles reads the config files at paths and deep-merges them in order into viper,
+// replacing the previously loaded config. Env var references in string values are expanded
+// with lookupEnv, while references to config keys accepted by isKeyRef are kept. Directories are expanded to the fragments they
+// contain, and with WithProfiles the sections and overlays of profile are layered on top
+// of every file. It returns the layers that were applied.
+//
+// Maps are merged key by key, scalars of later files replace earlier ones, and slices are
+// replaced unless WithAppendSlices is set, in which case they are concatenated.
+func readConfigFiles(opts *initOptions, paths []string, profile string, lookupEnv func(string) (string, bool), isKeyRef func(string) bool) ([]*configLayer, error) {
+	paths, err := expandConfigPaths(opts, paths, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := make([]*configLayer, 0, len(paths))
+	merged := map[string]any{}
+	for _, path := range paths {
+		settings, err := readConfigFile(opts, path, lookupEnv, isKeyRef)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read config %q", path)
+		}
+		if opts.profiles {
+			applyProfileSection(opts, settings, profile)
+		}
+		mergeSettings(merged, settings, opts.appendSlices)
+		layers = append(layers, &configLayer{path: path, settings: settings})
+	}
+
+	// ReadConfig is the only way to drop the config of a previous load.
+	opts.viperInstance.SetConfigType("json")
+	if err := opts.viperInstance.ReadConfig(strings.NewReader("{}")); err != nil {
+		return nil, errors.Wrap(err, "failed to reset config")
+	}
+	if err := opts.viperInstance.MergeConfigMap(merged); err != nil {
+		return nil, errors.Wrap(err, "failed to merge config")
+	}
+	if len(layers) > 0 {
+		opts.viperInstance.SetConfigFile(layers[len(layers)-1].path)
+	}
+	return layers, nil
+}
+
+// configExts are the extensions of the config files read from a config directory
+// or found by WithConfigSearch, in order of preference.
+var configExts = []string{".yaml", ".yml", ".json", ".toml"}
+
+// expandConfigPaths replaces every directory in paths with the config fragments it contains,
+// in lexical order. Subdirectories are not descended into. If profile is set, the existing
+// profile overlay of every config file is inserted right after it.
+func expandConfigPaths(opts *initOptions, paths []string, profile string) ([]string, error) {
+	var expanded []string
+	for _, path := range paths {
+		info, err := statFile(opts, path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read config %q", path)
+		}
+		if !info.IsDir() {
+			expanded = append(expanded, path)
+			if profile != "" && profileOverlayExists(opts, path, profile) {
+				expanded = append(expanded, profileOverlayPath(path, profile))
+			}
+			continue
+		}
+
+		var entries []fs.DirEntry
+		if opts.fs != nil {
+			entries, err = fs.ReadDir(opts.fs, path)
+		} else {
+			entries, err = os.ReadDir(path)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read config directory %q", path)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !lo.Contains(configExts, strings.ToLower(filepath.Ext(name))) {
+				continue
+			}
+			if opts.ignoreHidden && isHiddenFile(name) {
+				continue
+			}
+			expanded = append(expanded, joinPath(opts, path, name))
+		}
+	}
+	return expanded, nil
+}
+
+// configDirs returns the paths that are config directories.
+func configDirs(opts *initOptions, paths []string) []string {
+	var dirs []string
+	for _, path := range paths {
+		if info, err := statFile(opts, path); err == nil && info.IsDir() {
+			dirs = append(dirs, path)
+		}
+	}
+	return dirs
+}
+
+// isHiddenFile reports whether name is a dotfile or an editor artifact such as
+// a vim backup (app.yaml~), an Emacs autosave (#app.yaml#) or lock file (.#app.yaml).
+func isHiddenFile(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "#") || strings.HasSuffix(name, "~")
+}
+
+// statFile returns the FileInfo of path, from the filesystem set by WithFS or from the OS filesystem.
+func statFile(opts *initOptions, path string) (fs.FileInfo, error) {
+	if opts.fs != nil {
+		return fs.Stat(opts.fs, path)
+	}
+	return os.Stat(path)
+}
+
+// joinPath joins dir and name with the separator of the filesystem set by WithFS
+// or of the OS filesystem.
+func joinPath(opts *initOptions, dir, name string) string {
+	if opts.fs != nil {
+		return pathpkg.Join(dir, name)
+	}
+	return filepath.Join(dir, name)
+}
+
+// readFile reads the file at path, from the filesystem set by WithFS or from the OS filesystem.
+func readFile(opts *initOptions, path string) ([]byte, error) {
+	if opts.fs != nil {
+		return fs.ReadFile(opts.fs, path)
+	}
+	return os.ReadFile(path)
+}
+
+// readConfigFile decodes the config file at path and expands the env var references in its
+// string values with lookupEnv, keeping the references to config keys accepted by isKeyRef.
+// The config type is derived from the file extension.
+func readConfigFile(opts *initOptions, path string, lookupEnv func(string) (string, bool), isKeyRef func(string) bool) (map[string]any, error) {
+	data, err := readFile(opts, path)
+	if err != nil {
+		return nil, err
+	}
+	v := viper.New()
+	v.SetConfigType(strings.TrimPrefix(filepath.Ext(path), "."))
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	settings := v.AllSettings()
+	if err := interpolateSettings(settings, lookupEnv, isKeyRef); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// mergeSettings deep-merges src into dst.
+func mergeSettings(dst, src map[string]any, appendSlices bool) {
+	for key, srcVal := range src {
+		dstVal, ok := dst[key]
+		if !ok {
+			dst[key] = srcVal
+			continue
+		}
+		srcMap, srcIsMap := srcVal.(map[string]any)
+		dstMap, dstIsMap := dstVal.(map[string]any)
+		if srcIsMap && dstIsMap {
+			merged := make(map[string]any, len(dstMap))
+			for k, v := range dstMap {
+				merged[k] = v
+			}
+			mergeSettings(merged, srcMap, appendSlices)
+			dst[key] = merged
+			continue
+		}
+		srcSlice, srcIsSlice := srcVal.([]any)
+		dstSlice, dstIsSlice := dstVal.([]any)
+		if appendSlices && srcIsSlice && dstIsSlice {
+			dst[key] = append(append([]any{}, dstSlice...), srcSlice...)
+			continue
+		}
+		dst[key] = srcVal
+	}
+}
+
+// lookupSetting reports whether the dotted viper key is present in settings.
+func lookupSetting(settings map[string]any, key string) bool {
+	_, ok := getSetting(settings, strings.ToLower(key))
+	return ok
+}
@@ -0,0 +1,219 @@
+package confx_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/qor5/confx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mergeServerConfig struct {
+	Host string `confx:"host"`
+	Port int    `confx:"port"`
+}
+
+type mergeConfig struct {
+	Server mergeServerConfig `confx:"server"`
+	Labels map[string]string `confx:"labels"`
+	Tags   []string          `confx:"tags"`
+	Debug  bool              `confx:"debug"`
+}
+
+var mergeFS = fstest.MapFS{
+	"base.yaml":    {Data: []byte("server:\n  host: base.example.com\n  port: 1000\nlabels:\n  team: core\n  tier: base\ntags: [a, b]\n")},
+	"staging.json": {Data: []byte(`{"server": {"port": 2000}, "labels": {"tier": "staging"}, "tags": ["c"]}`)},
+	"local.toml":   {Data: []byte("debug = true\n\n[server]\nhost = \"localhost\"\n")},
+}
+
+func TestMultipleConfigFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		confPath string
+		options  []confx.Option
+		env      map[string]string
+		expected mergeConfig
+	}{
+		{
+			name:     "comma-separated paths",
+			confPath: "base.yaml,staging.json,local.toml",
+			expected: mergeConfig{
+				Server: mergeServerConfig{Host: "localhost", Port: 2000},
+				Labels: map[string]string{"team": "core", "tier": "staging"},
+				Tags:   []string{"c"},
+				Debug:  true,
+			},
+		},
+		{
+			name: "repeated config flag",
+			args: []string{"--config", "base.yaml", "-c", "staging.json"},
+			expected: mergeConfig{
+				Server: mergeServerConfig{Host: "base.example.com", Port: 2000},
+				Labels: map[string]string{"team": "core", "tier": "staging"},
+				Tags:   []string{"c"},
+			},
+		},
+		{
+			name:     "order matters",
+			confPath: "staging.json,base.yaml",
+			expected: mergeConfig{
+				Server: mergeServerConfig{Host: "base.example.com", Port: 1000},
+				Labels: map[string]string{"team": "core", "tier": "base"},
+				Tags:   []string{"a", "b"},
+			},
+		},
+		{
+			name:     "append slices",
+			confPath: "base.yaml,staging.json",
+			options:  []confx.Option{confx.WithAppendSlices()},
+			expected: mergeConfig{
+				Server: mergeServerConfig{Host: "base.example.com", Port: 2000},
+				Labels: map[string]string{"team": "core", "tier": "staging"},
+				Tags:   []string{"a", "b", "c"},
+			},
+		},
+		{
+			name:     "env over merged files",
+			confPath: "base.yaml,staging.json",
+			env:      map[string]string{"SERVER_PORT": "3000"},
+			expected: mergeConfig{
+				Server: mergeServerConfig{Host: "base.example.com", Port: 3000},
+				Labels: map[string]string{"team": "core", "tier": "staging"},
+				Tags:   []string{"c"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			options := append([]confx.Option{
+				confx.WithArgs(tt.args),
+				confx.WithLookupEnv(func(key string) (string, bool) {
+					val, ok := tt.env[key]
+					return val, ok
+				}),
+				confx.WithFS(mergeFS),
+			}, tt.options...)
+			loader, err := confx.Initialize(mergeConfig{Server: mergeServerConfig{Port: 8080}}, options...)
+			require.NoError(t, err)
+
+			conf, err := loader(context.Background(), tt.confPath)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, conf)
+		})
+	}
+}
+
+func TestMultipleConfigFilesReport(t *testing.T) {
+	loader, err := confx.Initialize(mergeConfig{}, confx.WithArgs(nil), confx.WithFS(mergeFS))
+	require.NoError(t, err)
+
+	report := &confx.LoadReport{}
+	_, err = loader(confx.WithLoadReport(context.Background(), report), "base.yaml,staging.json,local.toml")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"base.yaml", "staging.json", "local.toml"}, report.ConfigFiles)
+	assert.Equal(t, confx.Provenance{
+		Origin: confx.Origin{Source: confx.SourceFile, Name: "local.toml"},
+		Overridden: []confx.Origin{
+			{Source: confx.SourceFile, Name: "base.yaml"},
+			{Source: confx.SourceDefault},
+		},
+	}, report.Provenance["server.host"])
+	assert.Equal(t, confx.Provenance{
+		Origin: confx.Origin{Source: confx.SourceFile, Name: "staging.json"},
+		Overridden: []confx.Origin{
+			{Source: confx.SourceFile, Name: "base.yaml"},
+			{Source: confx.SourceDefault},
+		},
+	}, report.Provenance["server.port"])
+}
+
+func TestMultipleConfigFilesReload(t *testing.T) {
+	loader, err := confx.Initialize(mergeConfig{}, confx.WithArgs(nil), confx.WithFS(mergeFS))
+	require.NoError(t, err)
+
+	conf, err := loader(context.Background(), "base.yaml,local.toml")
+	require.NoError(t, err)
+	assert.True(t, conf.Debug)
+
+	// Keys of files that are no longer loaded do not leak into the next load.
+	conf, err = loader(context.Background(), "staging.json")
+	require.NoError(t, err)
+	assert.Equal(t, mergeConfig{
+		Server: mergeServerConfig{Port: 2000},
+		Labels: map[string]string{"tier": "staging"},
+		Tags:   []string{"c"},
+	}, conf)
+
+	_, err = loader(context.Background(), "base.yaml,missing.yaml")
+	require.ErrorContains(t, err, `failed to read config "missing.yaml"`)
+}
+
+func TestConfigDirectory(t *testing.T) {
+	fsys := fstest.MapFS{
+		"conf.d/10-server.yaml":  {Data: []byte("server:\n  host: base.example.com\n  port: 1000\n")},
+		"conf.d/20-labels.json":  {Data: []byte(`{"labels": {"team": "core"}}`)},
+		"conf.d/30-server.toml":  {Data: []byte("[server]\nport = 3000\n")},
+		"conf.d/README.md":       {Data: []byte("not a config fragment")},
+		"conf.d/nested/99.yaml":  {Data: []byte("labels:\n  nested: \"true\"\n")},
+		"conf.d/.#30-server.yml": {Data: []byte("debug: true\n")},
+		"conf.d/40-tags.yaml~":   {Data: []byte("tags: [backup]\n")},
+		"local.yaml":             {Data: []byte("tags: [local]\n")},
+	}
+
+	t.Run("fragments in lexical order", func(t *testing.T) {
+		loader, err := confx.Initialize(mergeConfig{},
+			confx.WithArgs([]string{"--config", "conf.d", "--config", "local.yaml"}),
+			confx.WithFS(fsys),
+			confx.WithIgnoreHiddenFiles(),
+		)
+		require.NoError(t, err)
+
+		report := &confx.LoadReport{}
+		conf, err := loader(confx.WithLoadReport(context.Background(), report), "")
+		require.NoError(t, err)
+		assert.Equal(t, mergeConfig{
+			Server: mergeServerConfig{Host: "base.example.com", Port: 3000},
+			Labels: map[string]string{"team": "core"},
+			Tags:   []string{"local"},
+		}, conf)
+		assert.Equal(t, []string{
+			"conf.d/10-server.yaml",
+			"conf.d/20-labels.json",
+			"conf.d/30-server.toml",
+			"local.yaml",
+		}, report.ConfigFiles)
+	})
+
+	t.Run("hidden files included by default", func(t *testing.T) {
+		loader, err := confx.Initialize(mergeConfig{}, confx.WithArgs(nil), confx.WithFS(fsys))
+		require.NoError(t, err)
+
+		conf, err := loader(context.Background(), "conf.d")
+		require.NoError(t, err)
+		assert.True(t, conf.Debug)
+	})
+
+	t.Run("os filesystem", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "b.yaml"), []byte("server:\n  port: 2000\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a.yaml"), []byte("server:\n  host: a\n  port: 1000\n"), 0o644))
+
+		loader, err := confx.Initialize(mergeConfig{}, confx.WithArgs(nil))
+		require.NoError(t, err)
+
+		report := &confx.LoadReport{}
+		conf, err := loader(confx.WithLoadReport(context.Background(), report), dir)
+		require.NoError(t, err)
+		assert.Equal(t, mergeServerConfig{Host: "a", Port: 2000}, conf.Server)
+		assert.Equal(t, []string{filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")}, report.ConfigFiles)
+	})
+}
@@ -1,6 +1,11 @@
 package confx
 
 import (
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/go-playground/validator/v10"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
@@ -21,6 +26,42 @@ type initOptions struct {
 	viperInstance *viper.Viper
 	validator     Validator
 	fieldHook     func(f *Field) (*Field, error)
+	helpError     bool
+	args          []string
+	lookupEnv     func(key string) (string, bool)
+	environ       func() []string
+	fs            fs.FS
+	appendSlices  bool
+	ignoreHidden  bool
+	profiles      bool
+	searchName    string
+	searchDirs    []string
+	searchAll     bool
+	strict        bool
+	envCheck      bool
+	envCheckFail  bool
+	envFiles      []string
+	resolvers     map[string]*secretResolver
+	ageKeyFile    string
+}
+
+func newInitOptions(options ...Option) *initOptions {
+	opts := &initOptions{
+		flagSet:       nil,
+		envPrefix:     "",
+		tagName:       DefaultTagName,
+		usageTagName:  DefaultUsageTagName,
+		viperInstance: viper.New(),
+		validator:     validator.New(validator.WithRequiredStructEnabled()),
+		lookupEnv:     os.LookupEnv,
+	}
+	for _, opt := range options {
+		opt(opts)
+	}
+	if opts.environ == nil {
+		opts.environ = os.Environ
+	}
+	return opts
 }
 
 // WithFlagSet sets a custom pflag.FlagSet instance for parsing command line flags
@@ -74,7 +115,8 @@ func WithValidator(v Validator) Option {
 }
 
 // WithViper sets a custom Viper instance for reading configuration from different sources.
-// If not set, the default viper.GetViper() is used.
+// If not set, every Initialize call creates its own instance with viper.New().
+// Loads of one Loader are serialized, but Loaders sharing an instance are not synchronized with each other.
 func WithViper(v *viper.Viper) Option {
 	if v == nil {
 		panic("viperInstance cannot be nil")
@@ -105,3 +147,184 @@ func WithUsageTagName(usageTagName string) Option {
 		opts.usageTagName = usageTagName
 	}
 }
+
+// WithHelpError makes the Loader return an error wrapping pflag.ErrHelp when --help is passed,
+// instead of calling os.Exit(0). The usage text is still printed by the flag set.
+// This lets the embedding application run deferred cleanup and decide how to exit.
+func WithHelpError() Option {
+	return func(opts *initOptions) {
+		opts.helpError = true
+	}
+}
+
+// WithArgs sets the command line arguments, without the program name, that the Loader parses.
+// If not set, os.Args[1:] is used. It has no effect if the flag set has already been parsed.
+func WithArgs(args []string) Option {
+	if args == nil {
+		args = []string{}
+	}
+	return func(opts *initOptions) {
+		opts.args = args
+	}
+}
+
+// WithLookupEnv sets the function used to look up environment variables.
+// If not set, os.LookupEnv is used. As with the process environment, empty values are ignored.
+// Unless WithEnviron is set as well, WithUnknownEnvCheck then sees no env vars but those of env files.
+func WithLookupEnv(lookupEnv func(key string) (string, bool)) Option {
+	if lookupEnv == nil {
+		panic("lookupEnv cannot be nil")
+	}
+	return func(opts *initOptions) {
+		opts.lookupEnv = lookupEnv
+		if opts.environ == nil {
+			opts.environ = func() []string { return nil }
+		}
+	}
+}
+
+// WithEnviron sets the function that lists the environment variables in "key=value" form for
+// WithUnknownEnvCheck, like os.Environ, to go with WithLookupEnv. If not set, os.Environ is used.
+func WithEnviron(environ func() []string) Option {
+	if environ == nil {
+		panic("environ cannot be nil")
+	}
+	return func(opts *initOptions) {
+		opts.environ = environ
+	}
+}
+
+// WithFS sets the filesystem that config files are read from.
+// Config paths are then interpreted as fs.FS paths, e.g. "config/app.yaml".
+// If not set, config files are read from the OS filesystem.
+// Loaders using WithFS cannot be watched with Loader.Watch.
+func WithFS(fsys fs.FS) Option {
+	if fsys == nil {
+		panic("fsys cannot be nil")
+	}
+	return func(opts *initOptions) {
+		opts.fs = fsys
+	}
+}
+
+// WithAppendSlices makes slices of later config files append to the slices of earlier ones
+// when several config files are merged. If not set, a slice in a later file replaces the
+// earlier one, while maps are always merged key by key.
+func WithAppendSlices() Option {
+	return func(opts *initOptions) {
+		opts.appendSlices = true
+	}
+}
+
+// WithIgnoreHiddenFiles makes config directories skip dotfiles and editor artifacts,
+// i.e. fragments whose name starts with "." or "#" or ends with "~".
+func WithIgnoreHiddenFiles() Option {
+	return func(opts *initOptions) {
+		opts.ignoreHidden = true
+	}
+}
+
+// WithProfiles enables profile overlays, selected with the --profile flag or the PROFILE
+// env var under the env prefix. The flag is added to the flag set unless it already exists.
+//
+// When a profile is selected, every config file, e.g. config.yaml, is followed by its overlay
+// config.<profile>.yaml if that file exists, and the profiles.<profile> section inside a config
+// file is merged on top of the rest of that file. The profiles section is never part of the
+// loaded configuration.
+func WithProfiles() Option {
+	return func(opts *initOptions) {
+		opts.profiles = true
+	}
+}
+
+// WithConfigSearch makes the Loader look for a config file named name, with any of the
+// .yaml, .yml, .json and .toml extensions, in dirs when no config path is given.
+// Dirs are searched in order and the first match is used, see also WithConfigSearchAll.
+// StandardConfigDirs returns the usual locations of the config of an application.
+// The files that were found are listed in LoadReport.ConfigFiles.
+func WithConfigSearch(name string, dirs ...string) Option {
+	if name == "" {
+		panic("name cannot be empty")
+	}
+	if len(dirs) == 0 {
+		panic("dirs cannot be empty")
+	}
+	return func(opts *initOptions) {
+		opts.searchName = name
+		opts.searchDirs = dirs
+	}
+}
+
+// WithConfigSearchAll makes WithConfigSearch merge the matches of all search dirs
+// instead of using the first one. Dirs listed first take precedence.
+func WithConfigSearchAll() Option {
+	return func(opts *initOptions) {
+		opts.searchAll = true
+	}
+}
+
+// WithStrict makes the Loader fail with an *UnknownKeysError when a config file contains
+// keys that do not match any configuration field, e.g. a misspelled "sever.port".
+// Every unknown key is listed with its file and the closest known key.
+func WithStrict() Option {
+	return func(opts *initOptions) {
+		opts.strict = true
+	}
+}
+
+// WithUnknownEnvCheck makes the Loader scan the environment, see WithEnviron, for variables that carry
+// the env prefix but do not match any configuration field, e.g. a misspelled APP_SERVR_PORT.
+// They are listed in LoadReport.UnknownKeys, or, if fail is true, make the Loader fail with
+// an *UnknownKeysError. It has no effect without WithEnvPrefix.
+func WithUnknownEnvCheck(fail bool) Option {
+	return func(opts *initOptions) {
+		opts.envCheck = true
+		opts.envCheckFail = fail
+	}
+}
+
+// WithEnvFile makes the Loader read env vars from the dotenv files at paths, later files taking
+// precedence. The files support quoting, comments and export prefixes, and files that do not
+// exist are skipped. Their values are bound like env vars, but the process environment takes
+// precedence over them. The --env-file flag is added to the flag set unless it already exists,
+// and replaces paths when passed; the files passed via the flag must exist.
+func WithEnvFile(paths ...string) Option {
+	if len(paths) == 0 {
+		panic("paths cannot be empty")
+	}
+	return func(opts *initOptions) {
+		opts.envFiles = paths
+	}
+}
+
+// WithSecretResolver registers resolver for string values of the form <scheme>://<ref>, e.g.
+// "secret://vault/db#password". After all sources are merged and before validation, every such
+// value is replaced with the secret returned by resolver for <ref>, so that config files only hold
+// references. Each call to the resolver is bounded by timeout, if positive, and by the context
+// passed to the Loader. Registering a scheme again replaces its resolver.
+func WithSecretResolver(scheme string, resolver SecretResolver, timeout time.Duration) Option {
+	if scheme == "" {
+		panic("scheme cannot be empty")
+	}
+	if resolver == nil {
+		panic("resolver cannot be nil")
+	}
+	return func(opts *initOptions) {
+		if opts.resolvers == nil {
+			opts.resolvers = map[string]*secretResolver{}
+		}
+		opts.resolvers[scheme] = &secretResolver{resolver: resolver, timeout: timeout}
+	}
+}
+
+// WithAgeKeyFile sets the path of a file with the age identities used to decrypt config values of
+// the form ENC[age,...], in addition to the identities in the CONFX_AGE_KEY env var and the file
+// named by the CONFX_AGE_KEY_FILE env var. The file is only read if an encrypted value is loaded.
+func WithAgeKeyFile(path string) Option {
+	if path == "" {
+		panic("age key file cannot be empty")
+	}
+	return func(opts *initOptions) {
+		opts.ageKeyFile = path
+	}
+}
@@ -1,7 +1,10 @@
 package confx
 
 import (
+	"os"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/pflag"
@@ -67,3 +70,183 @@ func TestWithValidator(t *testing.T) {
 		WithValidator(nil)
 	})
 }
+
+func TestWithHelpError(t *testing.T) {
+	opts := &initOptions{}
+	WithHelpError()(opts)
+
+	assert.True(t, opts.helpError)
+}
+
+func TestWithArgs(t *testing.T) {
+	opts := &initOptions{}
+	WithArgs([]string{"--port", "8080"})(opts)
+	assert.Equal(t, []string{"--port", "8080"}, opts.args)
+
+	// nil means no arguments rather than os.Args.
+	WithArgs(nil)(opts)
+	assert.NotNil(t, opts.args)
+	assert.Empty(t, opts.args)
+}
+
+func TestWithLookupEnv(t *testing.T) {
+	t.Run("valid lookup", func(t *testing.T) {
+		opts := &initOptions{}
+		WithLookupEnv(func(key string) (string, bool) { return key, true })(opts)
+
+		val, ok := opts.lookupEnv("PORT")
+		assert.True(t, ok)
+		assert.Equal(t, "PORT", val)
+	})
+
+	t.Run("nil lookup", func(t *testing.T) {
+		assert.Panics(t, func() {
+			WithLookupEnv(nil)
+		})
+	})
+}
+
+func TestWithEnviron(t *testing.T) {
+	t.Run("valid environ", func(t *testing.T) {
+		opts := &initOptions{}
+		WithEnviron(func() []string { return []string{"PORT=8080"} })(opts)
+
+		assert.Equal(t, []string{"PORT=8080"}, opts.environ())
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		assert.NotNil(t, newInitOptions().environ)
+		assert.Empty(t, newInitOptions(WithLookupEnv(os.LookupEnv)).environ())
+
+		environ := func() []string { return []string{"PORT=8080"} }
+		assert.Equal(t, []string{"PORT=8080"}, newInitOptions(WithEnviron(environ), WithLookupEnv(os.LookupEnv)).environ())
+		assert.Equal(t, []string{"PORT=8080"}, newInitOptions(WithLookupEnv(os.LookupEnv), WithEnviron(environ)).environ())
+	})
+
+	t.Run("nil environ", func(t *testing.T) {
+		assert.Panics(t, func() {
+			WithEnviron(nil)
+		})
+	})
+}
+
+func TestWithFS(t *testing.T) {
+	t.Run("valid fs", func(t *testing.T) {
+		fsys := fstest.MapFS{}
+		opts := &initOptions{}
+		WithFS(fsys)(opts)
+
+		assert.Equal(t, fsys, opts.fs)
+	})
+
+	t.Run("nil fs", func(t *testing.T) {
+		assert.Panics(t, func() {
+			WithFS(nil)
+		})
+	})
+}
+
+func TestWithAppendSlices(t *testing.T) {
+	opts := &initOptions{}
+	WithAppendSlices()(opts)
+
+	assert.True(t, opts.appendSlices)
+}
+
+func TestWithIgnoreHiddenFiles(t *testing.T) {
+	opts := &initOptions{}
+	WithIgnoreHiddenFiles()(opts)
+
+	assert.True(t, opts.ignoreHidden)
+}
+
+func TestWithProfiles(t *testing.T) {
+	opts := &initOptions{}
+	WithProfiles()(opts)
+
+	assert.True(t, opts.profiles)
+}
+
+func TestWithConfigSearch(t *testing.T) {
+	t.Run("valid search", func(t *testing.T) {
+		opts := &initOptions{}
+		WithConfigSearch("app", ".", "/etc/app")(opts)
+		WithConfigSearchAll()(opts)
+
+		assert.Equal(t, "app", opts.searchName)
+		assert.Equal(t, []string{".", "/etc/app"}, opts.searchDirs)
+		assert.True(t, opts.searchAll)
+	})
+
+	t.Run("empty name", func(t *testing.T) {
+		assert.Panics(t, func() {
+			WithConfigSearch("", ".")
+		})
+	})
+
+	t.Run("no dirs", func(t *testing.T) {
+		assert.Panics(t, func() {
+			WithConfigSearch("app")
+		})
+	})
+}
+
+func TestWithStrict(t *testing.T) {
+	opts := &initOptions{}
+	WithStrict()(opts)
+
+	assert.True(t, opts.strict)
+}
+
+func TestWithUnknownEnvCheck(t *testing.T) {
+	opts := &initOptions{}
+	WithUnknownEnvCheck(true)(opts)
+
+	assert.True(t, opts.envCheck)
+	assert.True(t, opts.envCheckFail)
+}
+
+func TestWithEnvFile(t *testing.T) {
+	t.Run("valid paths", func(t *testing.T) {
+		opts := &initOptions{}
+		WithEnvFile(".env", ".env.local")(opts)
+
+		assert.Equal(t, []string{".env", ".env.local"}, opts.envFiles)
+	})
+
+	t.Run("no paths", func(t *testing.T) {
+		assert.Panics(t, func() {
+			WithEnvFile()
+		})
+	})
+}
+
+func TestWithSecretResolver(t *testing.T) {
+	t.Run("valid resolver", func(t *testing.T) {
+		opts := &initOptions{}
+		WithSecretResolver("secret", MapSecretResolver{}, time.Second)(opts)
+		WithSecretResolver("env", EnvSecretResolver{}, 0)(opts)
+
+		assert.Len(t, opts.resolvers, 2)
+		assert.Equal(t, time.Second, opts.resolvers["secret"].timeout)
+	})
+
+	t.Run("invalid resolver", func(t *testing.T) {
+		assert.Panics(t, func() {
+			WithSecretResolver("", MapSecretResolver{}, 0)
+		})
+		assert.Panics(t, func() {
+			WithSecretResolver("secret", nil, 0)
+		})
+	})
+}
+
+func TestWithAgeKeyFile(t *testing.T) {
+	opts := &initOptions{}
+	WithAgeKeyFile("/etc/app/age.key")(opts)
+	assert.Equal(t, "/etc/app/age.key", opts.ageKeyFile)
+
+	assert.Panics(t, func() {
+		WithAgeKeyFile("")
+	})
+}
@@ -0,0 +1,59 @@
+package confx
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	profileFlagName   = "profile"
+	profileEnvName    = "PROFILE"
+	profileSectionKey = "profiles"
+)
+
+// resolveProfile returns the profile selected by the --profile flag, falling back to
+// the PROFILE env var under the env prefix. It returns "" if profiles are not enabled.
+func resolveProfile(opts *initOptions, lookupEnv func(string) (string, bool)) (string, error) {
+	if !opts.profiles {
+		return "", nil
+	}
+	profile := ""
+	if flag := opts.flagSet.Lookup(profileFlagName); flag != nil && flag.Changed {
+		profile = flag.Value.String()
+	} else if val, ok := lookupEnv(opts.envPrefix + profileEnvName); ok {
+		profile = val
+	}
+	profile = strings.TrimSpace(profile)
+	if strings.ContainsAny(profile, `/\`) || profile == "." || profile == ".." {
+		return "", errors.Errorf("invalid profile %q", profile)
+	}
+	return profile, nil
+}
+
+// profileOverlayPath returns the path of the profile overlay of the config file at path,
+// e.g. config.staging.yaml for config.yaml.
+func profileOverlayPath(path, profile string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
+
+// profileOverlayExists reports whether the profile overlay of the config file at path exists.
+func profileOverlayExists(opts *initOptions, path, profile string) bool {
+	info, err := statFile(opts, profileOverlayPath(path, profile))
+	return err == nil && !info.IsDir()
+}
+
+// applyProfileSection removes the profiles section from settings and merges the
+// section of the selected profile, if any, on top of the remaining settings.
+func applyProfileSection(opts *initOptions, settings map[string]any, profile string) {
+	sections, ok := settings[profileSectionKey].(map[string]any)
+	if !ok {
+		return
+	}
+	delete(settings, profileSectionKey)
+	if section, ok := sections[strings.ToLower(profile)].(map[string]any); ok && profile != "" {
+		mergeSettings(settings, section, opts.appendSlices)
+	}
+}
@@ -0,0 +1,134 @@
+package confx_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/qor5/confx"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfiles(t *testing.T) {
+	type Config struct {
+		Host  string `confx:"host"`
+		Port  int    `confx:"port"`
+		Debug bool   `confx:"debug"`
+	}
+
+	fsys := fstest.MapFS{
+		"config.yaml":         {Data: []byte("host: base\nport: 1000\nprofiles:\n  dev:\n    debug: true\n    port: 2000\n  prod:\n    host: prod.example.com\n")},
+		"config.prod.yaml":    {Data: []byte("port: 443\n")},
+		"config.staging.yaml": {Data: []byte("host: staging.example.com\n")},
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		files    []string
+		expected Config
+	}{
+		{
+			name:     "no profile",
+			files:    []string{"config.yaml"},
+			expected: Config{Host: "base", Port: 1000},
+		},
+		{
+			name:     "section only",
+			args:     []string{"--profile", "dev"},
+			files:    []string{"config.yaml"},
+			expected: Config{Host: "base", Port: 2000, Debug: true},
+		},
+		{
+			name:     "overlay only",
+			env:      map[string]string{"APP_PROFILE": "staging"},
+			files:    []string{"config.yaml", "config.staging.yaml"},
+			expected: Config{Host: "staging.example.com", Port: 1000},
+		},
+		{
+			name:     "section and overlay",
+			args:     []string{"--profile=prod"},
+			files:    []string{"config.yaml", "config.prod.yaml"},
+			expected: Config{Host: "prod.example.com", Port: 443},
+		},
+		{
+			name:     "flag over env",
+			args:     []string{"--profile", "dev"},
+			env:      map[string]string{"APP_PROFILE": "prod"},
+			files:    []string{"config.yaml"},
+			expected: Config{Host: "base", Port: 2000, Debug: true},
+		},
+		{
+			name:     "unknown profile",
+			args:     []string{"--profile", "qa"},
+			files:    []string{"config.yaml"},
+			expected: Config{Host: "base", Port: 1000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			loader, err := confx.Initialize(Config{},
+				confx.WithEnvPrefix("APP_"),
+				confx.WithArgs(tt.args),
+				confx.WithLookupEnv(func(key string) (string, bool) {
+					val, ok := tt.env[key]
+					return val, ok
+				}),
+				confx.WithFS(fsys),
+				confx.WithProfiles(),
+			)
+			require.NoError(t, err)
+
+			report := &confx.LoadReport{}
+			conf, err := loader(confx.WithLoadReport(context.Background(), report), "config.yaml")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, conf)
+			assert.Equal(t, tt.files, report.ConfigFiles)
+		})
+	}
+
+	t.Run("invalid profile", func(t *testing.T) {
+		loader, err := confx.Initialize(Config{},
+			confx.WithArgs([]string{"--profile", "../etc"}),
+			confx.WithFS(fsys),
+			confx.WithProfiles(),
+		)
+		require.NoError(t, err)
+
+		_, err = loader(context.Background(), "config.yaml")
+		require.ErrorContains(t, err, `invalid profile "../etc"`)
+	})
+
+	t.Run("existing profile flag", func(t *testing.T) {
+		flagSet := pflag.NewFlagSet("test_profiles", pflag.ContinueOnError)
+		flagSet.String("profile", "", "Deployment profile")
+		loader, err := confx.Initialize(Config{},
+			confx.WithFlagSet(flagSet),
+			confx.WithArgs([]string{"--profile", "dev"}),
+			confx.WithFS(fsys),
+			confx.WithProfiles(),
+		)
+		require.NoError(t, err)
+
+		conf, err := loader(context.Background(), "config.yaml")
+		require.NoError(t, err)
+		assert.True(t, conf.Debug)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		loader, err := confx.Initialize(Config{},
+			confx.WithArgs([]string{"--profile", "dev"}),
+			confx.WithFS(fsys),
+		)
+		require.NoError(t, err)
+
+		_, err = loader(context.Background(), "config.yaml")
+		require.ErrorContains(t, err, "unknown flag: --profile")
+	})
+}
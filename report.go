@@ -0,0 +1,104 @@
+package confx
+
+import (
+	"context"
+
+	"github.com/spf13/pflag"
+)
+
+// LoadReport describes how the configuration returned by a Loader was assembled.
+// Attach it to the context passed to the Loader with WithLoadReport.
+type LoadReport struct {
+	// ConfigFiles lists the config files that were read, in the order they were applied.
+	ConfigFiles []string
+	// Profile is the profile selected with WithProfiles, or empty.
+	Profile string
+	// UnknownKeys lists the unknown env vars found by WithUnknownEnvCheck when it does not fail the load.
+	UnknownKeys []UnknownKey
+	// Provenance records, for every viper key, which source supplied the final value.
+	Provenance map[string]Provenance
+
+	// configDirs lists the config directories the config files were read from, for Watch.
+	configDirs []string
+	// fs reports whether the config files were read from the fs.FS set by WithFS, which Watch
+	// cannot watch.
+	fs bool
+}
+
+type loadReportKey struct{}
+
+// WithLoadReport returns a copy of ctx that makes the Loader fill report while loading.
+func WithLoadReport(ctx context.Context, report *LoadReport) context.Context {
+	return context.WithValue(ctx, loadReportKey{}, report)
+}
+
+func loadReportFromContext(ctx context.Context) *LoadReport {
+	report, _ := ctx.Value(loadReportKey{}).(*LoadReport)
+	return report
+}
+
+// Source identifies where a configuration value came from.
+type Source string
+
+// Sources in ascending order of precedence.
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnvFile Source = "env-file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Origin is a single source that supplied a value for a key.
+type Origin struct {
+	Source Source
+	// Name is the flag name, env var name or file path, depending on Source.
+	// For SourceEnvFile, it is the path of the dotenv file.
+	// It is empty for SourceDefault.
+	Name string
+}
+
+// Provenance records the winning origin of a key and the lower-precedence origins it overrode.
+type Provenance struct {
+	Origin
+	// Overridden lists the lower-precedence origins that also supplied a value, highest first.
+	Overridden []Origin
+}
+
+// buildProvenance computes the provenance of every registered field, following the
+// precedence used by viper: flag > env > env file > file > default. Later config files
+// and env files take precedence over earlier ones.
+func buildProvenance(flagSet *pflag.FlagSet, lookupEnv func(string) (string, bool), fileEnv map[string]string, envFiles []*envFile, fields []*fieldInfo, layers []*configLayer) map[string]Provenance {
+	provenance := make(map[string]Provenance, len(fields))
+	for _, f := range fields {
+		if f.nested {
+			continue
+		}
+		var origins []Origin
+		if flag := flagSet.Lookup(f.FlagKey); flag != nil && flag.Changed {
+			origins = append(origins, Origin{Source: SourceFlag, Name: f.FlagKey})
+		}
+		if val, ok := lookupEnv(f.EnvKey); ok && val != "" {
+			origins = append(origins, Origin{Source: SourceEnv, Name: f.EnvKey})
+		}
+		if _, ok := fileEnv[f.EnvKey]; ok {
+			origins = append(origins, Origin{Source: SourceEnv, Name: f.EnvKey + fileEnvSuffix})
+		}
+		if file, val, ok := lookupEnvFiles(envFiles, f.EnvKey); ok && val != "" {
+			origins = append(origins, Origin{Source: SourceEnvFile, Name: file.path})
+		}
+		for i := len(layers) - 1; i >= 0; i-- {
+			if lookupSetting(layers[i].settings, f.ViperKey) {
+				origins = append(origins, Origin{Source: SourceFile, Name: layers[i].path})
+			}
+		}
+		origins = append(origins, Origin{Source: SourceDefault})
+
+		p := Provenance{Origin: origins[0]}
+		if len(origins) > 1 {
+			p.Overridden = origins[1:]
+		}
+		provenance[f.ViperKey] = p
+	}
+	return provenance
+}
@@ -0,0 +1,76 @@
+package confx_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qor5/confx"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadReportProvenance(t *testing.T) {
+	viper.Reset()
+
+	type ServerConfig struct {
+		Host string `confx:"host"`
+		Port int    `confx:"port"`
+	}
+	type Config struct {
+		Server   ServerConfig `confx:"server"`
+		LogLevel string       `confx:"logLevel"`
+		Verbose  bool         `confx:"verbose"`
+	}
+
+	configFilePath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFilePath, []byte("server:\n  host: file.example.com\n  port: 8081\nlogLevel: warn\n"), 0o644))
+
+	flagSet := pflag.NewFlagSet("test_provenance", pflag.ContinueOnError)
+	loader, err := confx.Initialize(Config{
+		Server:   ServerConfig{Host: "localhost", Port: 8080},
+		LogLevel: "info",
+	}, confx.WithFlagSet(flagSet), confx.WithEnvPrefix("APP_"))
+	require.NoError(t, err)
+
+	t.Setenv("APP_SERVER_PORT", "8082")
+	t.Setenv("APP_LOG_LEVEL", "debug")
+	require.NoError(t, flagSet.Parse([]string{"--log-level=error"}))
+
+	report := &confx.LoadReport{}
+	conf, err := loader(confx.WithLoadReport(context.Background(), report), configFilePath)
+	require.NoError(t, err)
+	assert.Equal(t, Config{
+		Server:   ServerConfig{Host: "file.example.com", Port: 8082},
+		LogLevel: "error",
+	}, conf)
+
+	assert.Equal(t, []string{configFilePath}, report.ConfigFiles)
+	assert.Equal(t, map[string]confx.Provenance{
+		"server.host": {
+			Origin:     confx.Origin{Source: confx.SourceFile, Name: configFilePath},
+			Overridden: []confx.Origin{{Source: confx.SourceDefault}},
+		},
+		"server.port": {
+			Origin: confx.Origin{Source: confx.SourceEnv, Name: "APP_SERVER_PORT"},
+			Overridden: []confx.Origin{
+				{Source: confx.SourceFile, Name: configFilePath},
+				{Source: confx.SourceDefault},
+			},
+		},
+		"logLevel": {
+			Origin: confx.Origin{Source: confx.SourceFlag, Name: "log-level"},
+			Overridden: []confx.Origin{
+				{Source: confx.SourceEnv, Name: "APP_LOG_LEVEL"},
+				{Source: confx.SourceFile, Name: configFilePath},
+				{Source: confx.SourceDefault},
+			},
+		},
+		"verbose": {
+			Origin: confx.Origin{Source: confx.SourceDefault},
+		},
+	}, report.Provenance)
+}
@@ -0,0 +1,166 @@
+package confx
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SecretResolver resolves secret references of the form <scheme>://<ref> found in string values.
+// It is passed the part after "://".
+type SecretResolver interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// SecretResolverFunc adapts a function to a SecretResolver.
+type SecretResolverFunc func(ctx context.Context, ref string) (string, error)
+
+// Resolve implements SecretResolver.
+func (f SecretResolverFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+// MapSecretResolver resolves references from an in-memory map, e.g. in tests.
+type MapSecretResolver map[string]string
+
+// Resolve implements SecretResolver.
+func (m MapSecretResolver) Resolve(_ context.Context, ref string) (string, error) {
+	val, ok := m[ref]
+	if !ok {
+		return "", errors.Errorf("secret %q not found", ref)
+	}
+	return val, nil
+}
+
+// FileSecretResolver resolves a reference to the content of the file at that path,
+// without the trailing newline, e.g. file:///run/secrets/db.
+type FileSecretResolver struct{}
+
+// Resolve implements SecretResolver.
+func (FileSecretResolver) Resolve(_ context.Context, ref string) (string, error) {
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// EnvSecretResolver resolves a reference to the value of the env var of that name, e.g. env://DB_PASSWORD.
+// Within a Loader, env vars are looked up like env bindings, i.e. with WithLookupEnv and WithEnvFile
+// if set, and with os.LookupEnv otherwise.
+type EnvSecretResolver struct{}
+
+// Resolve implements SecretResolver.
+func (EnvSecretResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	lookupEnv, ok := ctx.Value(lookupEnvKey{}).(func(string) (string, bool))
+	if !ok {
+		lookupEnv = os.LookupEnv
+	}
+	val, ok := lookupEnv(ref)
+	if !ok {
+		return "", errors.Errorf("env var %s is not set", ref)
+	}
+	return val, nil
+}
+
+// ExecSecretResolver resolves a reference to the output of the command it describes, without
+// the trailing newline, e.g. exec://pass show db. The command is split on white space and
+// is not run by a shell. It is killed when the context is done.
+type ExecSecretResolver struct{}
+
+// Resolve implements SecretResolver.
+func (ExecSecretResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	args := strings.Fields(ref)
+	if len(args) == 0 {
+		return "", errors.New("empty command")
+	}
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", errors.Wrapf(err, "command %q failed: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", errors.Wrapf(err, "command %q failed", args[0])
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// secretResolver is a SecretResolver registered with WithSecretResolver.
+type secretResolver struct {
+	resolver SecretResolver
+	timeout  time.Duration
+}
+
+// lookupEnvKey is the context key of the env lookup of the Loader, for EnvSecretResolver.
+type lookupEnvKey struct{}
+
+// resolveSecretRefs replaces the string values of settings that start with the scheme of a
+// registered resolver with the value it resolves, in place, escaped for resolveKeyReferences.
+// Each reference is resolved once.
+func resolveSecretRefs(ctx context.Context, settings map[string]any, resolvers map[string]*secretResolver, lookupEnv func(string) (string, bool)) error {
+	if len(resolvers) == 0 {
+		return nil
+	}
+	ctx = context.WithValue(ctx, lookupEnvKey{}, lookupEnv)
+	cache := map[string]string{}
+	var resolveValue func(val any, key string) (any, error)
+	resolveValue = func(val any, key string) (any, error) {
+		switch v := val.(type) {
+		case string:
+			scheme, ref, ok := strings.Cut(v, "://")
+			r := resolvers[scheme]
+			if !ok || r == nil {
+				return v, nil
+			}
+			if resolved, ok := cache[v]; ok {
+				return resolved, nil
+			}
+			resolved, err := r.resolve(ctx, ref)
+			if err != nil {
+				return nil, errors.Wrapf(err, "key %q: %s resolver", key, scheme)
+			}
+			resolved = escapeReferences(resolved)
+			cache[v] = resolved
+			return resolved, nil
+		case map[string]any:
+			for _, k := range sortedKeys(v) {
+				resolved, err := resolveValue(v[k], key+"."+k)
+				if err != nil {
+					return nil, err
+				}
+				v[k] = resolved
+			}
+		case []any:
+			for i := range v {
+				resolved, err := resolveValue(v[i], fmt.Sprintf("%s[%d]", key, i))
+				if err != nil {
+					return nil, err
+				}
+				v[i] = resolved
+			}
+		}
+		return val, nil
+	}
+	for _, key := range sortedKeys(settings) {
+		resolved, err := resolveValue(settings[key], key)
+		if err != nil {
+			return err
+		}
+		settings[key] = resolved
+	}
+	return nil
+}
+
+func (r *secretResolver) resolve(ctx context.Context, ref string) (string, error) {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	return r.resolver.Resolve(ctx, ref)
+}
@@ -0,0 +1,136 @@
+package confx_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/qor5/confx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretResolvers(t *testing.T) {
+	type DatabaseConfig struct {
+		User     string               `confx:"user"`
+		Password confx.Secret[string] `confx:"password" validate:"required"`
+	}
+	type Config struct {
+		Database DatabaseConfig    `confx:"database"`
+		Tokens   []string          `confx:"tokens"`
+		Keys     map[string]string `confx:"keys"`
+		URL      string            `confx:"url"`
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "user"), []byte("admin\n"), 0o600))
+
+	fsys := fstest.MapFS{
+		"config.yaml": {Data: []byte(`database:
+  user: file://` + filepath.Join(dir, "user") + `
+  password: secret://vault/db#password
+tokens:
+  - env://CONFX_TEST_TOKEN
+  - exec://echo exec-token
+keys:
+  signing: secret://vault/jwt#key
+url: https://example.com
+`)},
+		"missing.yaml": {Data: []byte("database:\n  password: secret://vault/missing\n")},
+		"slow.yaml":    {Data: []byte("database:\n  password: slow://db\n")},
+	}
+
+	slow := confx.SecretResolverFunc(func(ctx context.Context, _ string) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	newLoader := func() confx.Loader[Config] {
+		loader, err := confx.Initialize(Config{},
+			confx.WithArgs(nil),
+			confx.WithFS(fsys),
+			confx.WithLookupEnv(func(key string) (string, bool) {
+				if key == "CONFX_TEST_TOKEN" {
+					return "env-token", true
+				}
+				return "", false
+			}),
+			confx.WithSecretResolver("secret", confx.MapSecretResolver{
+				"vault/db#password": "s3cret",
+				"vault/jwt#key":     "jwt-key",
+			}, time.Second),
+			confx.WithSecretResolver("file", confx.FileSecretResolver{}, 0),
+			confx.WithSecretResolver("env", confx.EnvSecretResolver{}, 0),
+			confx.WithSecretResolver("exec", confx.ExecSecretResolver{}, 5*time.Second),
+			confx.WithSecretResolver("slow", slow, 10*time.Millisecond),
+		)
+		require.NoError(t, err)
+		return loader
+	}
+
+	conf, err := newLoader()(context.Background(), "config.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", conf.Database.User)
+	assert.Equal(t, "s3cret", conf.Database.Password.Value())
+	assert.Equal(t, []string{"env-token", "exec-token"}, conf.Tokens)
+	assert.Equal(t, map[string]string{"signing": "jwt-key"}, conf.Keys)
+	assert.Equal(t, "https://example.com", conf.URL)
+
+	_, err = newLoader()(context.Background(), "missing.yaml")
+	require.EqualError(t, err, `failed to resolve secret: key "database.password": secret resolver: secret "vault/missing" not found`)
+
+	_, err = newLoader()(context.Background(), "slow.yaml")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	loader, err := confx.Initialize(Config{},
+		confx.WithArgs(nil),
+		confx.WithFS(fsys),
+		confx.WithSecretResolver("slow", slow, 0),
+	)
+	require.NoError(t, err)
+	_, err = loader(ctx, "slow.yaml")
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSecretResolversWithKeyReferences(t *testing.T) {
+	type DatabaseConfig struct {
+		Password confx.Secret[string] `confx:"password"`
+		Token    string               `confx:"token"`
+	}
+	type Config struct {
+		Database DatabaseConfig `confx:"database"`
+		DSN      string         `confx:"dsn"`
+	}
+
+	loader, err := confx.Initialize(Config{},
+		confx.WithArgs(nil),
+		confx.WithFS(fstest.MapFS{"config.yaml": {Data: []byte(`database:
+  password: secret://db
+  token: secret://token
+dsn: postgres://u:${database.password}@h/app?token=${database.token}
+`)}}),
+		confx.WithSecretResolver("secret", confx.MapSecretResolver{
+			"db":    "s3cret",
+			"token": "a${b.c}",
+		}, 0),
+	)
+	require.NoError(t, err)
+	conf, err := loader(context.Background(), "config.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", conf.Database.Password.Value())
+	// Resolved secrets are taken literally, even if they look like references.
+	assert.Equal(t, "a${b.c}", conf.Database.Token)
+	assert.Equal(t, "postgres://u:s3cret@h/app?token=a${b.c}", conf.DSN)
+}
+
+func TestExecSecretResolverError(t *testing.T) {
+	_, err := confx.ExecSecretResolver{}.Resolve(context.Background(), "sh -c 'exit 1'")
+	require.Error(t, err)
+
+	_, err = confx.ExecSecretResolver{}.Resolve(context.Background(), " ")
+	require.EqualError(t, err, "empty command")
+}
@@ -0,0 +1,340 @@
+package confx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// GenerateSample renders def as an annotated configuration file of the given type
+// ("yaml", "yml", "toml" or "json"), walking the same fields that Initialize registers.
+//
+// Every key is preceded by a comment built from its usage text, validation rules, env var
+// name and flag name, so that an embedded default config file can be regenerated from the
+// default struct and checked in CI. JSON has no comments, so only the values are written.
+// Secret fields are written as their zero value, with a comment to set them via env or flag,
+// and so are secrets inside slices and maps, so that a sample read back as defaults never
+// holds a masked or real secret.
+//
+// Options that affect key, flag and env var names (WithTagName, WithUsageTagName,
+// WithEnvPrefix, WithFieldHook) are honoured. Any flag set passed via WithFlagSet is left untouched.
+func GenerateSample[T any](typ string, def T, options ...Option) ([]byte, error) {
+	opts := newInitOptions(options...)
+	opts.flagSet = pflag.NewFlagSet("sample", pflag.ContinueOnError)
+
+	var collectBinds []func() error
+	var collectFields []*fieldInfo
+	if err := initializeRecursive(opts, reflect.ValueOf(def), "", &collectBinds, &collectFields); err != nil {
+		return nil, err
+	}
+
+	root := newKeyTree(collectFields)
+
+	var buf bytes.Buffer
+	switch strings.ToLower(strings.TrimLeft(typ, ".")) {
+	case "yaml", "yml":
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(root.yamlNode(opts.tagName)); err != nil {
+			return nil, errors.Wrap(err, "failed to encode yaml")
+		}
+		if err := enc.Close(); err != nil {
+			return nil, errors.Wrap(err, "failed to encode yaml")
+		}
+	case "toml":
+		root.writeTOML(&buf, nil, opts.tagName)
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(root.plainValue(opts.tagName)); err != nil {
+			return nil, errors.Wrap(err, "failed to encode json")
+		}
+	default:
+		return nil, errors.Errorf("unsupported sample type %q", typ)
+	}
+	return buf.Bytes(), nil
+}
+
+// comment returns the annotation lines of a leaf.
+func (n *keyNode) comment() []string {
+	f := n.field
+	var lines []string
+	if f.Usage != "" && f.Usage != f.ViperKey {
+		lines = append(lines, f.Usage)
+	}
+	if f.secret {
+		lines = append(lines, "secret: the default is not written, set it via env or flag")
+	}
+	details := []string{"env: " + f.EnvKey, "flag: --" + f.FlagKey}
+	if rules := f.structField.Tag.Get("validate"); rules != "" {
+		details = append(details, "validate: "+rules)
+	}
+	return append(lines, strings.Join(details, ", "))
+}
+
+func (n *keyNode) leafValue(tagName string) any {
+	f := n.field
+	if f.secret {
+		return samplePlainValue(reflect.Zero(f.typ), tagName)
+	}
+	return samplePlainValue(f.value, tagName)
+}
+
+func (n *keyNode) plainValue(tagName string) any {
+	if n.isLeaf() {
+		return n.leafValue(tagName)
+	}
+	m := make(orderedMap, 0, len(n.children))
+	for _, child := range n.children {
+		m = append(m, orderedEntry{Key: child.key, Value: child.plainValue(tagName)})
+	}
+	return m
+}
+
+func (n *keyNode) yamlNode(tagName string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, child := range n.children {
+		keyScalar := &yaml.Node{Kind: yaml.ScalarNode, Value: child.key}
+		var valueNode *yaml.Node
+		if child.isLeaf() {
+			keyScalar.HeadComment = strings.Join(child.comment(), "\n")
+			valueNode = &yaml.Node{}
+			_ = valueNode.Encode(child.leafValue(tagName))
+		} else {
+			valueNode = child.yamlNode(tagName)
+		}
+		node.Content = append(node.Content, keyScalar, valueNode)
+	}
+	return node
+}
+
+func (n *keyNode) writeTOML(buf *bytes.Buffer, path []string, tagName string) {
+	var tables []*keyNode
+	for _, child := range n.children {
+		if !child.isLeaf() {
+			tables = append(tables, child)
+			continue
+		}
+		for _, line := range child.comment() {
+			buf.WriteString("# " + line + "\n")
+		}
+		buf.WriteString(tomlKey(child.key) + " = " + tomlValue(child.leafValue(tagName)) + "\n")
+	}
+	for _, child := range tables {
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		childPath := append(append([]string{}, path...), tomlKey(child.key))
+		buf.WriteString("[" + strings.Join(childPath, ".") + "]\n")
+		child.writeTOML(buf, childPath, tagName)
+	}
+}
+
+var reTOMLBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(key string) string {
+	if reTOMLBareKey.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+func tomlString(s string) string {
+	// JSON string escapes are a subset of TOML basic string escapes.
+	bs, _ := json.Marshal(s)
+	return string(bs)
+}
+
+func tomlValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return `""`
+	case string:
+		return tomlString(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = tomlValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case orderedMap:
+		if len(v) == 0 {
+			return "{}"
+		}
+		items := make([]string, len(v))
+		for i, entry := range v {
+			items[i] = tomlKey(entry.Key) + " = " + tomlValue(entry.Value)
+		}
+		return "{ " + strings.Join(items, ", ") + " }"
+	default:
+		return tomlString(fmt.Sprint(v))
+	}
+}
+
+// secretForm is how plainValue writes secrets.
+type secretForm int
+
+const (
+	secretZero     secretForm = iota // the zero value, e.g. for samples
+	secretMasked                     // SecretMask, e.g. for help output
+	secretRevealed                   // the real value, e.g. for flag defaults
+)
+
+// samplePlainValue converts v into plain values (strings, numbers, bools, []any and orderedMap)
+// using the same textual forms that confx decodes from config files. Secrets are written as
+// their zero value, so that a sample read back as defaults holds no secret.
+func samplePlainValue(v reflect.Value, tagName string) any {
+	return plainValue(v, tagName, secretZero)
+}
+
+// plainValue converts v like samplePlainValue, writing secrets in the given form.
+func plainValue(v reflect.Value, tagName string, form secretForm) any {
+	v = unwrapOrNew(v)
+	t := v.Type()
+	switch {
+	case isSecretType(t):
+		inner := v.Interface().(secretValuer).secretValue()
+		switch form {
+		case secretZero:
+			return plainValue(reflect.Zero(inner.Type()), tagName, form)
+		case secretMasked:
+			return SecretMask
+		}
+		return plainValue(inner, tagName, form)
+	case t == typeDuration:
+		return FormatDuration(time.Duration(v.Int()))
+	case t == typeTime:
+		return v.Interface().(time.Time).Format(time.RFC3339)
+	case isTextType(t):
+		return formatText(v)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return base64.StdEncoding.EncodeToString(v.Bytes())
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = plainValue(v.Index(i), tagName, form)
+		}
+		return items
+	case reflect.Map:
+		m := make(orderedMap, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			m = append(m, orderedEntry{Key: fmt.Sprint(key.Interface()), Value: plainValue(v.MapIndex(key), tagName, form)})
+		}
+		sort.Slice(m, func(i, j int) bool { return m[i].Key < m[j].Key })
+		return m
+	case reflect.Struct:
+		m := orderedMap{}
+		appendStructEntries(&m, v, tagName, form)
+		return m
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+func appendStructEntries(m *orderedMap, v reflect.Value, tagName string, form secretForm) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !ast.IsExported(field.Name) {
+			continue
+		}
+		tag := strings.TrimSpace(field.Tag.Get(tagName))
+		if tag == "-" {
+			continue
+		}
+		if tag == ",squash" {
+			appendStructEntries(m, unwrapOrNew(v.Field(i)), tagName, form)
+			continue
+		}
+		if tag == "" {
+			tag = field.Name
+		}
+		val := plainValue(v.Field(i), tagName, form)
+		if field.Tag.Get(DefaultSecretTagName) == "true" {
+			switch form {
+			case secretZero:
+				val = plainValue(reflect.Zero(field.Type), tagName, form)
+			case secretMasked:
+				val = SecretMask
+			}
+		}
+		*m = append(*m, orderedEntry{Key: tag, Value: val})
+	}
+}
+
+type orderedEntry struct {
+	Key   string
+	Value any
+}
+
+// orderedMap is a map that keeps the order of its keys when marshaled.
+type orderedMap []orderedEntry
+
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, entry := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (m orderedMap) MarshalYAML() (any, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, entry := range m {
+		valueNode := &yaml.Node{}
+		if err := valueNode.Encode(entry.Value); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: entry.Key}, valueNode)
+	}
+	return node, nil
+}
@@ -0,0 +1,204 @@
+package confx_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/qor5/confx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type SampleServerConfig struct {
+	Host    string        `confx:"host" usage:"Server host address" validate:"required"`
+	Port    int           `confx:"port" usage:"Server port" validate:"gte=1,lte=65535"`
+	Timeout time.Duration `confx:"timeout" usage:"Request timeout"`
+}
+
+type SampleCommonDBConfig struct {
+	Name     string `confx:"name" usage:"Database name"`
+	Password string `confx:"password" usage:"Database password" secret:"true"`
+}
+
+type SampleDatabaseConfig struct {
+	SampleCommonDBConfig `confx:",squash"`
+	Replicas             []string `confx:"replicas" usage:"Replica hosts"`
+}
+
+type SampleConfig struct {
+	Server   SampleServerConfig   `confx:"server"`
+	Database SampleDatabaseConfig `confx:"database"`
+	Labels   map[string]string    `confx:"labels"`
+	Token    confx.Secret[string] `confx:"token" usage:"API token"`
+	Debug    bool                 `confx:"debug"`
+}
+
+var sampleDefault = SampleConfig{
+	Server:   SampleServerConfig{Host: "localhost", Port: 8080, Timeout: 30 * time.Second},
+	Database: SampleDatabaseConfig{SampleCommonDBConfig: SampleCommonDBConfig{Name: "app", Password: "secret"}, Replicas: []string{"r1", "r2"}},
+	Labels:   map[string]string{"team": "core", "env": "dev"},
+	Token:    confx.NewSecret("token"),
+}
+
+func TestGenerateSampleYAML(t *testing.T) {
+	bs, err := confx.GenerateSample("yaml", sampleDefault, confx.WithEnvPrefix("APP_"))
+	require.NoError(t, err)
+	assert.Equal(t, `server:
+  # Server host address
+  # env: APP_SERVER_HOST, flag: --server-host, validate: required
+  host: localhost
+  # Server port
+  # env: APP_SERVER_PORT, flag: --server-port, validate: gte=1,lte=65535
+  port: 8080
+  # Request timeout
+  # env: APP_SERVER_TIMEOUT, flag: --server-timeout
+  timeout: 30s
+database:
+  # Database name
+  # env: APP_DATABASE_NAME, flag: --database-name
+  name: app
+  # Database password
+  # secret: the default is not written, set it via env or flag
+  # env: APP_DATABASE_PASSWORD, flag: --database-password
+  password: ""
+  # Replica hosts
+  # env: APP_DATABASE_REPLICAS, flag: --database-replicas
+  replicas:
+    - r1
+    - r2
+# env: APP_LABELS, flag: --labels
+labels:
+  env: dev
+  team: core
+# API token
+# secret: the default is not written, set it via env or flag
+# env: APP_TOKEN, flag: --token
+token: ""
+# env: APP_DEBUG, flag: --debug
+debug: false
+`, string(bs))
+
+	// The generated file can be read back as defaults.
+	conf, err := confx.Read[SampleConfig]("yaml", bytes.NewReader(bs))
+	require.NoError(t, err)
+	assert.Equal(t, sampleDefault.Server, conf.Server)
+	assert.Equal(t, sampleDefault.Labels, conf.Labels)
+	assert.Equal(t, sampleDefault.Database.Replicas, conf.Database.Replicas)
+	assert.Empty(t, conf.Database.Password)
+	assert.Empty(t, conf.Token.Value())
+}
+
+func TestGenerateSampleTOML(t *testing.T) {
+	bs, err := confx.GenerateSample("toml", sampleDefault)
+	require.NoError(t, err)
+	assert.Equal(t, `# env: LABELS, flag: --labels
+labels = { env = "dev", team = "core" }
+# API token
+# secret: the default is not written, set it via env or flag
+# env: TOKEN, flag: --token
+token = ""
+# env: DEBUG, flag: --debug
+debug = false
+
+[server]
+# Server host address
+# env: SERVER_HOST, flag: --server-host, validate: required
+host = "localhost"
+# Server port
+# env: SERVER_PORT, flag: --server-port, validate: gte=1,lte=65535
+port = 8080
+# Request timeout
+# env: SERVER_TIMEOUT, flag: --server-timeout
+timeout = "30s"
+
+[database]
+# Database name
+# env: DATABASE_NAME, flag: --database-name
+name = "app"
+# Database password
+# secret: the default is not written, set it via env or flag
+# env: DATABASE_PASSWORD, flag: --database-password
+password = ""
+# Replica hosts
+# env: DATABASE_REPLICAS, flag: --database-replicas
+replicas = ["r1", "r2"]
+`, string(bs))
+
+	conf, err := confx.Read[SampleConfig]("toml", bytes.NewReader(bs))
+	require.NoError(t, err)
+	assert.Equal(t, sampleDefault.Server, conf.Server)
+	assert.Equal(t, sampleDefault.Labels, conf.Labels)
+}
+
+func TestGenerateSampleJSON(t *testing.T) {
+	bs, err := confx.GenerateSample("json", sampleDefault, confx.WithTagName("confx"))
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "server": {
+    "host": "localhost",
+    "port": 8080,
+    "timeout": "30s"
+  },
+  "database": {
+    "name": "app",
+    "password": "",
+    "replicas": [
+      "r1",
+      "r2"
+    ]
+  },
+  "labels": {
+    "env": "dev",
+    "team": "core"
+  },
+  "token": "",
+  "debug": false
+}
+`, string(bs))
+
+	conf, err := confx.Read[SampleConfig]("json", bytes.NewReader(bs))
+	require.NoError(t, err)
+	assert.Equal(t, sampleDefault.Server, conf.Server)
+}
+
+func TestGenerateSampleNestedSecrets(t *testing.T) {
+	type Upstream struct {
+		Host  string               `confx:"host"`
+		Token confx.Secret[string] `confx:"token"`
+		Key   string               `confx:"key" secret:"true"`
+	}
+	type Config struct {
+		Upstreams []Upstream                      `confx:"upstreams"`
+		Tokens    map[string]confx.Secret[string] `confx:"tokens"`
+	}
+	def := Config{
+		Upstreams: []Upstream{{Host: "a.example.com", Token: confx.NewSecret("real-token"), Key: "real-key"}},
+		Tokens:    map[string]confx.Secret[string]{"ci": confx.NewSecret("real-ci")},
+	}
+
+	bs, err := confx.GenerateSample("json", def)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "upstreams": [{"host": "a.example.com", "token": "", "key": ""}],
+  "tokens": {"ci": ""}
+}`, string(bs))
+
+	bs, err = confx.GenerateJSONSchema(def)
+	require.NoError(t, err)
+	assert.NotContains(t, string(bs), "real-")
+	assert.NotContains(t, string(bs), confx.SecretMask)
+	assert.Contains(t, string(bs), `"default": {
+        "ci": ""
+      }`)
+}
+
+func TestGenerateSampleErrors(t *testing.T) {
+	_, err := confx.GenerateSample("ini", sampleDefault)
+	require.ErrorContains(t, err, `unsupported sample type "ini"`)
+
+	_, err = confx.GenerateSample("yaml", struct {
+		Chan chan int `confx:"chan"`
+	}{})
+	require.ErrorContains(t, err, `unsupported field type "chan int" (chan) for key "chan"`)
+}
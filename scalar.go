@@ -0,0 +1,228 @@
+package confx
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// isScalarType reports whether values of t are parsed from a single string by parseScalar.
+func isScalarType(t reflect.Type) bool {
+	if t == typeDuration || t == typeTime || isTextType(t) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseScalar parses s as a value of the scalar type t. Numbers are checked against the size of t,
+// durations accept the units of ParseDuration and times are in RFC3339 format.
+func parseScalar(t reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	switch {
+	case t == typeDuration:
+		d, err := ParseDuration(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetInt(int64(d))
+		return v, nil
+	case t == typeTime:
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.Set(reflect.ValueOf(tm))
+		return v, nil
+	case isTextType(t):
+		if err := setText(v.Addr(), s); err != nil {
+			return reflect.Value{}, err
+		}
+		return v, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		v.SetString(s)
+	default:
+		return reflect.Value{}, errors.Errorf("unsupported type %q", t)
+	}
+	return v, nil
+}
+
+// formatScalar returns the text form of the scalar v, which parseScalar parses back.
+func formatScalar(v reflect.Value) string {
+	t := v.Type()
+	switch {
+	case t == typeDuration:
+		return FormatDuration(time.Duration(v.Int()))
+	case t == typeTime:
+		return v.Interface().(time.Time).Format(time.RFC3339)
+	case isTextType(t):
+		return formatText(v)
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, t.Bits())
+	default:
+		return v.String()
+	}
+}
+
+// scalarTypeName names the scalar type t in the flag type of its slices and maps, e.g. int8Slice.
+func scalarTypeName(t reflect.Type) string {
+	switch {
+	case t == typeDuration:
+		return "duration"
+	case t == typeTime:
+		return "time"
+	case isTextType(t):
+		return "text"
+	}
+	return t.Kind().String()
+}
+
+// sliceValue is the pflag.Value of a slice or array of scalars without a dedicated pflag type.
+// Like the pflag slices, it takes comma-separated values, and repeating the flag appends to it.
+// Values are validated when set and decoded by StringToSliceHookFunc.
+type sliceValue struct {
+	elemType reflect.Type
+	size     int // the length of an array, or -1 for a slice
+	values   []string
+	changed  bool
+}
+
+func newSliceValue(fieldValue reflect.Value) *sliceValue {
+	s := &sliceValue{elemType: fieldValue.Type().Elem(), size: -1}
+	if fieldValue.Kind() == reflect.Array {
+		s.size = fieldValue.Len()
+	}
+	for i := 0; i < fieldValue.Len(); i++ {
+		s.values = append(s.values, formatScalar(fieldValue.Index(i)))
+	}
+	return s
+}
+
+func (s *sliceValue) Set(val string) error {
+	values := strings.Split(val, ",")
+	for i, v := range values {
+		values[i] = strings.TrimSpace(v)
+		if _, err := parseScalar(s.elemType, values[i]); err != nil {
+			return errors.Wrapf(err, "invalid value %q", v)
+		}
+	}
+	if s.changed {
+		values = append(s.values, values...)
+	}
+	if s.size >= 0 && len(values) > s.size {
+		return errors.Errorf("too many values, the array has length %d", s.size)
+	}
+	s.values = values
+	s.changed = true
+	return nil
+}
+
+func (s *sliceValue) String() string {
+	return "[" + strings.Join(s.values, ",") + "]"
+}
+
+func (s *sliceValue) Type() string {
+	if s.size >= 0 {
+		return "[" + strconv.Itoa(s.size) + "]" + scalarTypeName(s.elemType)
+	}
+	return scalarTypeName(s.elemType) + "Slice"
+}
+
+// mapValue is the pflag.Value of a map from strings to scalars without a dedicated pflag type.
+// Like the pflag maps, it takes comma-separated key=value pairs, and repeating the flag adds
+// to it. Values are validated when set and decoded by StringToMapHookFunc.
+type mapValue struct {
+	elemType reflect.Type
+	values   map[string]string
+	changed  bool
+}
+
+func newMapValue(fieldValue reflect.Value) *mapValue {
+	m := &mapValue{elemType: fieldValue.Type().Elem(), values: map[string]string{}}
+	for _, key := range fieldValue.MapKeys() {
+		m.values[key.String()] = formatScalar(fieldValue.MapIndex(key))
+	}
+	return m
+}
+
+func (m *mapValue) Set(val string) error {
+	values := map[string]string{}
+	for _, pair := range strings.Split(val, ",") {
+		key, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return errors.Errorf("invalid key-value pair %q", pair)
+		}
+		if _, err := parseScalar(m.elemType, strings.TrimSpace(v)); err != nil {
+			return errors.Wrapf(err, "invalid value %q for key %q", v, key)
+		}
+		values[key] = v
+	}
+	if !m.changed {
+		m.values = map[string]string{}
+	}
+	for key, v := range values {
+		m.values[key] = v
+	}
+	m.changed = true
+	return nil
+}
+
+func (m *mapValue) String() string {
+	pairs := make([]string, 0, len(m.values))
+	for key, v := range m.values {
+		pairs = append(pairs, key+"="+v)
+	}
+	sort.Strings(pairs)
+	return "[" + strings.Join(pairs, ",") + "]"
+}
+
+func (m *mapValue) Type() string {
+	name := scalarTypeName(m.elemType)
+	return "stringTo" + strings.ToUpper(name[:1]) + name[1:]
+}
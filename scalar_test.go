@@ -0,0 +1,138 @@
+package confx_test
+
+import (
+	"strconv"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/qor5/confx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type collectionConfig[T any] struct {
+	Values []T          `confx:"values"`
+	Pairs  map[string]T `confx:"pairs"`
+}
+
+// octet is a named uint8, since []uint8 is []byte, which is base64-encoded.
+type octet uint8
+
+// testCollections loads slices and maps of T from defaults, flags, env vars and a config file.
+// a and b are two values of T and textA and textB their text forms.
+func testCollections[T any](t *testing.T, a, b T, textA, textB string) {
+	def := collectionConfig[T]{
+		Values: []T{a},
+		Pairs:  map[string]T{"x": b},
+	}
+	fsys := fstest.MapFS{
+		"config.yaml": {Data: []byte("values: [" + strconv.Quote(textA) + ", " + strconv.Quote(textB) + "]\n" +
+			"pairs:\n  a: " + strconv.Quote(textA) + "\n  b: " + strconv.Quote(textB) + "\n")},
+	}
+	load := func(args []string, env map[string]string, path string) collectionConfig[T] {
+		t.Helper()
+		loader, err := confx.Initialize(def,
+			confx.WithArgs(args),
+			confx.WithFS(fsys),
+			confx.WithLookupEnv(func(key string) (string, bool) {
+				val, ok := env[key]
+				return val, ok
+			}),
+		)
+		require.NoError(t, err)
+		conf, err := loader(t.Context(), path)
+		require.NoError(t, err)
+		return conf
+	}
+	want := collectionConfig[T]{
+		Values: []T{a, b},
+		Pairs:  map[string]T{"a": a, "b": b},
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		assert.Equal(t, def, load(nil, nil, ""))
+	})
+	t.Run("flags", func(t *testing.T) {
+		assert.Equal(t, want, load([]string{"--values", textA + "," + textB, "--pairs", "a=" + textA + ",b=" + textB}, nil, ""))
+	})
+	t.Run("repeated flags", func(t *testing.T) {
+		assert.Equal(t, want, load([]string{"--values", textA, "--values", textB, "--pairs", "a=" + textA, "--pairs", "b=" + textB}, nil, ""))
+	})
+	t.Run("env", func(t *testing.T) {
+		assert.Equal(t, want, load(nil, map[string]string{"VALUES": textA + "," + textB, "PAIRS": "a=" + textA + ",b=" + textB}, ""))
+	})
+	t.Run("config file", func(t *testing.T) {
+		assert.Equal(t, want, load(nil, nil, "config.yaml"))
+	})
+}
+
+func TestCollectionElementTypes(t *testing.T) {
+	ts1 := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	ts2 := time.Date(2025, 6, 7, 8, 9, 10, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"bool", func(t *testing.T) { testCollections(t, true, false, "true", "false") }},
+		{"int", func(t *testing.T) { testCollections(t, -1, 2, "-1", "2") }},
+		{"int8", func(t *testing.T) { testCollections[int8](t, -128, 127, "-128", "127") }},
+		{"int16", func(t *testing.T) { testCollections[int16](t, -32768, 32767, "-32768", "32767") }},
+		{"int32", func(t *testing.T) { testCollections[int32](t, -1, 1<<30, "-1", "1073741824") }},
+		{"int64", func(t *testing.T) { testCollections[int64](t, -1, 1<<40, "-1", "1099511627776") }},
+		{"uint", func(t *testing.T) { testCollections[uint](t, 1, 2, "1", "2") }},
+		{"uint8", func(t *testing.T) { testCollections[octet](t, 0, 255, "0", "255") }},
+		{"uint16", func(t *testing.T) { testCollections[uint16](t, 1, 65535, "1", "65535") }},
+		{"uint32", func(t *testing.T) { testCollections[uint32](t, 1, 1<<31, "1", "2147483648") }},
+		{"uint64", func(t *testing.T) { testCollections[uint64](t, 1, 1<<63, "1", "9223372036854775808") }},
+		{"float32", func(t *testing.T) { testCollections[float32](t, 1.5, -2.25, "1.5", "-2.25") }},
+		{"float64", func(t *testing.T) { testCollections(t, 1.5, -2.25, "1.5", "-2.25") }},
+		{"string", func(t *testing.T) { testCollections(t, "a", "b c", "a", "b c") }},
+		{"duration", func(t *testing.T) { testCollections(t, time.Second, confx.Day, "1s", "1d") }},
+		{"time", func(t *testing.T) {
+			testCollections(t, ts1, ts2, ts1.Format(time.RFC3339), ts2.Format(time.RFC3339))
+		}},
+		{"byte size", func(t *testing.T) { testCollections(t, confx.KiB, 10*confx.MB, "1KiB", "10MB") }},
+		{"text", func(t *testing.T) { testCollections(t, LevelDebug, LevelError, "debug", "error") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, tt.run)
+	}
+}
+
+func TestCollectionInvalidValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "int8 out of range",
+			args:    []string{"--values", "1,128"},
+			wantErr: `invalid argument "1,128" for "--values" flag: invalid value "128"`,
+		},
+		{
+			name:    "uint16 map value out of range",
+			args:    []string{"--pairs", "a=70000"},
+			wantErr: `invalid argument "a=70000" for "--pairs" flag: invalid value "70000" for key "a"`,
+		},
+		{
+			name:    "invalid key-value pair",
+			args:    []string{"--pairs", "a"},
+			wantErr: `invalid key-value pair "a"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			type Config struct {
+				Values []int8            `confx:"values"`
+				Pairs  map[string]uint16 `confx:"pairs"`
+			}
+			loader, err := confx.Initialize(Config{}, confx.WithArgs(tt.args))
+			require.NoError(t, err)
+			_, err = loader(t.Context(), "")
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,427 @@
+package confx
+
+import (
+	"encoding/json"
+	"go/ast"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// JSONSchemaDialect is the JSON Schema draft produced by GenerateJSONSchema.
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// GenerateJSONSchema builds a JSON Schema (draft 2020-12) for config files of def,
+// using the confx key names, usage texts as descriptions and def as defaults.
+//
+// Common go-playground/validator rules are translated into schema constraints:
+//   - required: the value must not be empty; the key itself is only required when
+//     def holds the zero value, since defaults fill in missing keys.
+//   - oneof: enum.
+//   - gte, lte, gt, lt, min, max: minimum/maximum for numbers, minLength/maxLength
+//     for strings, minItems/maxItems for slices and minProperties/maxProperties for maps.
+//   - url, email: format "uri" and "email".
+//   - omitempty: the zero value is accepted in addition to the constraints.
+//   - dive: the following rules apply to slice items or map values.
+//
+// Nested structs tagged with skip_nested_unless only carry their constraints inside an
+// if/then conditional on the referenced sibling fields.
+//
+// With WithProfiles, the schema also accepts the profiles section, whose profiles follow
+// the schema of the config without its required keys, since they only override some keys.
+func GenerateJSONSchema[T any](def T, options ...Option) ([]byte, error) {
+	opts := newInitOptions(options...)
+	opts.flagSet = pflag.NewFlagSet("schema", pflag.ContinueOnError)
+
+	var collectBinds []func() error
+	var collectFields []*fieldInfo
+	if err := initializeRecursive(opts, reflect.ValueOf(def), "", &collectBinds, &collectFields); err != nil {
+		return nil, err
+	}
+
+	schema := newKeyTree(collectFields).schema(opts.tagName, true)
+	if opts.profiles {
+		schema["properties"].(map[string]any)[profileSectionKey] = map[string]any{
+			"type":                 "object",
+			"description":          "Config sections merged on top of the config when their profile is selected",
+			"additionalProperties": withoutRequired(schema),
+		}
+	}
+	schema["$schema"] = JSONSchemaDialect
+
+	bs, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal json schema")
+	}
+	return append(bs, '\n'), nil
+}
+
+// schema returns the schema of the node. When strict is false, only types, descriptions
+// and defaults are emitted, which is used for nested structs guarded by skip_nested_unless.
+func (n *keyNode) schema(tagName string, strict bool) map[string]any {
+	if n.isLeaf() {
+		f := n.field
+		s := typeSchema(f.typ, tagName, strict)
+		if f.Usage != "" && f.Usage != f.ViperKey {
+			s["description"] = f.Usage
+		}
+		if !f.secret {
+			s["default"] = samplePlainValue(f.value, tagName)
+		}
+		if strict {
+			applyValidateRules(s, f.typ, f.structField.Tag.Get("validate"), tagName)
+		}
+		return s
+	}
+
+	properties := map[string]any{}
+	var required []string
+	var allOf []any
+	for _, child := range n.children {
+		var rules string
+		if child.field != nil {
+			rules = child.field.structField.Tag.Get("validate")
+		}
+		conditions, guarded := skipNestedUnlessConditions(n, rules)
+		properties[child.key] = child.schema(tagName, strict && !guarded)
+		if !strict {
+			continue
+		}
+		if guarded {
+			allOf = append(allOf, map[string]any{
+				"if": conditions,
+				"then": map[string]any{
+					"properties": map[string]any{child.key: child.schema(tagName, true)},
+				},
+			})
+		}
+		if hasValidateRule(rules, "required") && child.field.value.IsZero() {
+			required = append(required, child.key)
+		}
+	}
+
+	s := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if n.field != nil && n.field.Usage != "" && n.field.Usage != n.field.ViperKey {
+		s["description"] = n.field.Usage
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	if len(allOf) > 0 {
+		s["allOf"] = allOf
+	}
+	return s
+}
+
+// withoutRequired returns a copy of the object schema s in which no key of s or of its
+// nested sections is required. Slice items and map values are whole values, so they keep
+// their required keys, as do the conditions of if/then conditionals.
+func withoutRequired(s map[string]any) map[string]any {
+	c := make(map[string]any, len(s))
+	for k, v := range s {
+		switch k {
+		case "required":
+			continue
+		case "properties":
+			properties := map[string]any{}
+			for key, property := range v.(map[string]any) {
+				properties[key] = withoutRequired(property.(map[string]any))
+			}
+			v = properties
+		case "then":
+			v = withoutRequired(v.(map[string]any))
+		case "allOf":
+			var allOf []any
+			for _, sub := range v.([]any) {
+				allOf = append(allOf, withoutRequired(sub.(map[string]any)))
+			}
+			v = allOf
+		}
+		c[k] = v
+	}
+	return c
+}
+
+// skipNestedUnlessConditions translates a skip_nested_unless rule into the "if" schema
+// matching the sibling fields of parent it refers to.
+func skipNestedUnlessConditions(parent *keyNode, rules string) (map[string]any, bool) {
+	for _, rule := range splitValidateRules(rules) {
+		name, param, _ := strings.Cut(rule, "=")
+		if name != skipNestedUnlessTag {
+			continue
+		}
+		params := parseOneOfParam2(param)
+		properties := map[string]any{}
+		var required []string
+		for i := 0; i+1 < len(params); i += 2 {
+			sibling := findChildByFieldName(parent, params[i])
+			if sibling == nil {
+				continue
+			}
+			properties[sibling.key] = map[string]any{"const": parseSchemaValue(sibling.field.typ, params[i+1])}
+			// A missing key falls back to its default, which only satisfies the
+			// condition if the default already matches.
+			if !reflect.DeepEqual(samplePlainValue(sibling.field.value, ""), parseSchemaValue(sibling.field.typ, params[i+1])) {
+				required = append(required, sibling.key)
+			}
+		}
+		conditions := map[string]any{"properties": properties}
+		if len(required) > 0 {
+			conditions["required"] = required
+		}
+		return conditions, true
+	}
+	return nil, false
+}
+
+func findChildByFieldName(parent *keyNode, name string) *keyNode {
+	for _, child := range parent.children {
+		if child.field != nil && child.field.structField.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// typeSchema returns the schema describing values of t as they appear in config files.
+func typeSchema(t reflect.Type, tagName string, strict bool) map[string]any {
+	t = unwrapType(t)
+	if isSecretType(t) {
+		return typeSchema(reflect.New(t).Elem().Interface().(secretValuer).secretValue().Type(), tagName, strict)
+	}
+	if t == typeByteSize {
+		return map[string]any{"type": []any{"integer", "string"}}
+	}
+	if isTextType(t) {
+		return map[string]any{"type": "string"}
+	}
+	switch t {
+	case typeDuration:
+		return map[string]any{"type": "string"}
+	case typeTime:
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		s := map[string]any{"type": "array", "items": typeSchema(t.Elem(), tagName, strict)}
+		if t.Kind() == reflect.Array {
+			s["maxItems"] = t.Len()
+		}
+		return s
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem(), tagName, strict)}
+	case reflect.Struct:
+		properties := map[string]any{}
+		var required []string
+		appendStructSchema(properties, &required, t, tagName, strict)
+		s := map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	default:
+		return map[string]any{}
+	}
+}
+
+func appendStructSchema(properties map[string]any, required *[]string, t reflect.Type, tagName string, strict bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !ast.IsExported(field.Name) {
+			continue
+		}
+		tag := strings.TrimSpace(field.Tag.Get(tagName))
+		if tag == "-" {
+			continue
+		}
+		if tag == ",squash" {
+			appendStructSchema(properties, required, unwrapType(field.Type), tagName, strict)
+			continue
+		}
+		if tag == "" {
+			tag = field.Name
+		}
+		s := typeSchema(field.Type, tagName, strict)
+		if strict {
+			rules := field.Tag.Get("validate")
+			applyValidateRules(s, field.Type, rules, tagName)
+			if hasValidateRule(rules, "required") {
+				*required = append(*required, tag)
+			}
+		}
+		properties[tag] = s
+	}
+}
+
+func splitValidateRules(rules string) []string {
+	if rules == "" {
+		return nil
+	}
+	return strings.Split(rules, ",")
+}
+
+func hasValidateRule(rules, name string) bool {
+	for _, rule := range splitValidateRules(rules) {
+		if rule == "dive" {
+			return false
+		}
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+// applyValidateRules adds the schema constraints equivalent to the validate rules of a value of type t.
+func applyValidateRules(s map[string]any, t reflect.Type, rules string, tagName string) {
+	t = unwrapType(t)
+	if isSecretType(t) {
+		t = reflect.New(t).Elem().Interface().(secretValuer).secretValue().Type()
+	}
+
+	constraints := map[string]any{}
+	omitempty := false
+	parts := splitValidateRules(rules)
+	for i, rule := range parts {
+		if rule == "dive" {
+			switch {
+			case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+				if items, ok := s["items"].(map[string]any); ok {
+					applyValidateRules(items, t.Elem(), strings.Join(parts[i+1:], ","), tagName)
+				}
+			case t.Kind() == reflect.Map:
+				if values, ok := s["additionalProperties"].(map[string]any); ok {
+					applyValidateRules(values, t.Elem(), strings.Join(parts[i+1:], ","), tagName)
+				}
+			}
+			break
+		}
+
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "omitempty":
+			omitempty = true
+		case "required":
+			switch t.Kind() {
+			case reflect.String:
+				constraints["minLength"] = 1
+			case reflect.Slice, reflect.Array:
+				constraints["minItems"] = 1
+			case reflect.Map:
+				constraints["minProperties"] = 1
+			case reflect.Struct:
+			default:
+				constraints["not"] = map[string]any{"const": samplePlainValue(reflect.Zero(t), tagName)}
+			}
+		case "oneof":
+			var enum []any
+			for _, v := range parseOneOfParam2(param) {
+				enum = append(enum, parseSchemaValue(t, v))
+			}
+			constraints["enum"] = enum
+		case "gte", "min":
+			addBoundConstraint(constraints, t, param, "minimum", "minLength", "minItems", "minProperties", 0)
+		case "gt":
+			addBoundConstraint(constraints, t, param, "exclusiveMinimum", "minLength", "minItems", "minProperties", 1)
+		case "lte", "max":
+			addBoundConstraint(constraints, t, param, "maximum", "maxLength", "maxItems", "maxProperties", 0)
+		case "lt":
+			addBoundConstraint(constraints, t, param, "exclusiveMaximum", "maxLength", "maxItems", "maxProperties", -1)
+		case "url":
+			constraints["format"] = "uri"
+		case "email":
+			constraints["format"] = "email"
+		}
+	}
+
+	if len(constraints) == 0 {
+		return
+	}
+	if omitempty {
+		s["anyOf"] = []any{
+			map[string]any{"const": samplePlainValue(reflect.Zero(t), tagName)},
+			constraints,
+		}
+		return
+	}
+	for k, v := range constraints {
+		s[k] = v
+	}
+}
+
+// addBoundConstraint adds a numeric or length bound depending on the kind of t.
+// offset adjusts exclusive length bounds, which JSON Schema only has in inclusive form.
+func addBoundConstraint(constraints map[string]any, t reflect.Type, param, numberKey, stringKey, sliceKey, mapKey string, offset int) {
+	if t == typeDuration || t == typeTime || isTextType(t) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if v, err := strconv.ParseFloat(param, 64); err == nil {
+			constraints[numberKey] = v
+		}
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return
+		}
+		switch t.Kind() {
+		case reflect.String:
+			constraints[stringKey] = n + offset
+		case reflect.Map:
+			constraints[mapKey] = n + offset
+		default:
+			constraints[sliceKey] = n + offset
+		}
+	}
+}
+
+// parseSchemaValue converts a validate tag parameter into the JSON value it stands for.
+func parseSchemaValue(t reflect.Type, s string) any {
+	switch unwrapType(t).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return v
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return v
+		}
+	case reflect.Float32, reflect.Float64:
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return v
+		}
+	case reflect.Bool:
+		if v, err := strconv.ParseBool(s); err == nil {
+			return v
+		}
+	}
+	return s
+}
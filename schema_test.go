@@ -0,0 +1,206 @@
+package confx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qor5/confx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type SchemaJWTConfig struct {
+	Secret string `confx:"secret" usage:"JWT secret key" validate:"required" secret:"true"`
+}
+
+type SchemaAuthConfig struct {
+	Provider string          `confx:"provider" usage:"Authentication provider" validate:"required,oneof=jwt basic"`
+	JWT      SchemaJWTConfig `confx:"jwt" validate:"skip_nested_unless=Provider jwt"`
+}
+
+type SchemaWebhook struct {
+	URL   string `confx:"url" validate:"required,url"`
+	Email string `confx:"email" validate:"omitempty,email"`
+}
+
+type SchemaConfig struct {
+	Port     int              `confx:"port" usage:"Server port" validate:"gte=1,lte=65535"`
+	Replicas int              `confx:"replicas" validate:"omitempty,min=2"`
+	Name     string           `confx:"name" validate:"required,max=32"`
+	Level    string           `confx:"level" validate:"oneof=debug info"`
+	Timeout  time.Duration    `confx:"timeout" validate:"gte=0"`
+	Tags     []string         `confx:"tags" validate:"min=1,dive,required"`
+	Weights  map[string]int   `confx:"weights" validate:"dive,gt=0"`
+	Webhooks []SchemaWebhook  `confx:"webhooks"`
+	Auth     SchemaAuthConfig `confx:"auth"`
+}
+
+func TestGenerateJSONSchema(t *testing.T) {
+	bs, err := confx.GenerateJSONSchema(SchemaConfig{
+		Port:    8080,
+		Level:   "info",
+		Timeout: time.Second,
+		Tags:    []string{"a"},
+		Auth:    SchemaAuthConfig{Provider: "basic", JWT: SchemaJWTConfig{Secret: "s3cr3t"}},
+	})
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "additionalProperties": false,
+  "required": ["name"],
+  "properties": {
+    "port": {"type": "integer", "description": "Server port", "default": 8080, "minimum": 1, "maximum": 65535},
+    "replicas": {"type": "integer", "default": 0, "anyOf": [{"const": 0}, {"minimum": 2}]},
+    "name": {"type": "string", "default": "", "minLength": 1, "maxLength": 32},
+    "level": {"type": "string", "default": "info", "enum": ["debug", "info"]},
+    "timeout": {"type": "string", "default": "1s"},
+    "tags": {"type": "array", "items": {"type": "string", "minLength": 1}, "default": ["a"], "minItems": 1},
+    "weights": {"type": "object", "additionalProperties": {"type": "integer", "exclusiveMinimum": 0}, "default": {}},
+    "webhooks": {
+      "type": "array",
+      "default": [],
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["url"],
+        "properties": {
+          "url": {"type": "string", "minLength": 1, "format": "uri"},
+          "email": {"type": "string", "anyOf": [{"const": ""}, {"format": "email"}]}
+        }
+      }
+    },
+    "auth": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "provider": {"type": "string", "description": "Authentication provider", "default": "basic", "minLength": 1, "enum": ["jwt", "basic"]},
+        "jwt": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "secret": {"type": "string", "description": "JWT secret key"}
+          }
+        }
+      },
+      "allOf": [
+        {
+          "if": {"properties": {"provider": {"const": "jwt"}}, "required": ["provider"]},
+          "then": {
+            "properties": {
+              "jwt": {
+                "type": "object",
+                "additionalProperties": false,
+                "properties": {
+                  "secret": {"type": "string", "description": "JWT secret key", "minLength": 1}
+                }
+              }
+            }
+          }
+        }
+      ]
+    }
+  }
+}`, string(bs))
+}
+
+func TestGenerateJSONSchemaSkipNestedUnlessDefault(t *testing.T) {
+	// When the default already satisfies the condition, a missing key matches as well.
+	bs, err := confx.GenerateJSONSchema(SchemaAuthConfig{Provider: "jwt"})
+	require.NoError(t, err)
+	assert.Contains(t, string(bs), `"if": {
+        "properties": {
+          "provider": {
+            "const": "jwt"
+          }
+        }
+      }`)
+}
+
+func TestGenerateJSONSchemaUnsupportedType(t *testing.T) {
+	_, err := confx.GenerateJSONSchema(struct {
+		Func func() `confx:"func"`
+	}{})
+	require.ErrorContains(t, err, `unsupported field type "func()" (func) for key "func"`)
+}
+
+func TestGenerateJSONSchemaNestedCollections(t *testing.T) {
+	bs, err := confx.GenerateJSONSchema(struct {
+		Zones      [3]string           `confx:"zones"`
+		Allowlists map[string][]string `confx:"allowlists"`
+	}{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "zones": {
+      "type": "array",
+      "items": {"type": "string"},
+      "maxItems": 3,
+      "default": ["", "", ""]
+    },
+    "allowlists": {
+      "type": "object",
+      "additionalProperties": {"type": "array", "items": {"type": "string"}},
+      "default": {}
+    }
+  }
+}`, string(bs))
+}
+
+func TestGenerateJSONSchemaProfiles(t *testing.T) {
+	bs, err := confx.GenerateJSONSchema(struct {
+		Name     string          `confx:"name" validate:"required"`
+		Webhooks []SchemaWebhook `confx:"webhooks"`
+	}{}, confx.WithProfiles())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "additionalProperties": false,
+  "required": ["name"],
+  "properties": {
+    "name": {"type": "string", "default": "", "minLength": 1},
+    "webhooks": {
+      "type": "array",
+      "default": [],
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["url"],
+        "properties": {
+          "url": {"type": "string", "minLength": 1, "format": "uri"},
+          "email": {"type": "string", "anyOf": [{"const": ""}, {"format": "email"}]}
+        }
+      }
+    },
+    "profiles": {
+      "type": "object",
+      "description": "Config sections merged on top of the config when their profile is selected",
+      "additionalProperties": {
+        "type": "object",
+        "additionalProperties": false,
+        "properties": {
+          "name": {"type": "string", "default": "", "minLength": 1},
+          "webhooks": {
+            "type": "array",
+            "default": [],
+            "items": {
+              "type": "object",
+              "additionalProperties": false,
+              "required": ["url"],
+              "properties": {
+                "url": {"type": "string", "minLength": 1, "format": "uri"},
+                "email": {"type": "string", "anyOf": [{"const": ""}, {"format": "email"}]}
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`, string(bs))
+}
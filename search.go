@@ -0,0 +1,47 @@
+package confx
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// StandardConfigDirs returns the standard locations of the config of app, in order of precedence:
+// the working directory, $XDG_CONFIG_HOME/<app> (~/.config/<app> if unset), ~/.<app> and /etc/<app>.
+// Locations under the home directory are omitted if it cannot be determined.
+func StandardConfigDirs(app string) []string {
+	dirs := []string{"."}
+	home, _ := os.UserHomeDir()
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		dirs = append(dirs, filepath.Join(xdg, app))
+	} else if home != "" {
+		dirs = append(dirs, filepath.Join(home, ".config", app))
+	}
+	if home != "" {
+		dirs = append(dirs, filepath.Join(home, "."+app))
+	}
+	return append(dirs, filepath.Join("/etc", app))
+}
+
+// searchConfigFiles looks for the config file set by WithConfigSearch in every search dir.
+// It returns the first match, or with WithConfigSearchAll every match, ordered from the
+// lowest to the highest precedence so that they can be merged in order.
+func searchConfigFiles(opts *initOptions) []string {
+	if opts.searchName == "" {
+		return nil
+	}
+	var found []string
+	for _, dir := range opts.searchDirs {
+		for _, ext := range configExts {
+			path := joinPath(opts, dir, opts.searchName+ext)
+			if info, err := statFile(opts, path); err != nil || info.IsDir() {
+				continue
+			}
+			if !opts.searchAll {
+				return []string{path}
+			}
+			found = append([]string{path}, found...)
+			break
+		}
+	}
+	return found
+}
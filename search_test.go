@@ -0,0 +1,88 @@
+package confx_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/qor5/confx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStandardConfigDirs(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	assert.Equal(t, []string{".", "/xdg/myapp", filepath.Join(home, ".myapp"), "/etc/myapp"}, confx.StandardConfigDirs("myapp"))
+
+	t.Setenv("XDG_CONFIG_HOME", "")
+	assert.Equal(t, filepath.Join(home, ".config", "myapp"), confx.StandardConfigDirs("myapp")[1])
+}
+
+func TestConfigSearch(t *testing.T) {
+	type Config struct {
+		Host string `confx:"host"`
+		Port int    `confx:"port"`
+	}
+
+	fsys := fstest.MapFS{
+		"user/app.json":   {Data: []byte(`{"port": 2000}`)},
+		"user/app.toml":   {Data: []byte("port = 2001\n")},
+		"system/app.yaml": {Data: []byte("host: system.example.com\nport: 3000\n")},
+		"other/app.yaml":  {Data: []byte("host: other.example.com\n")},
+		"empty/app.yaml":  {Data: []byte("host: dir\n"), Mode: os.ModeDir},
+	}
+
+	tests := []struct {
+		name     string
+		confPath string
+		options  []confx.Option
+		files    []string
+		expected Config
+	}{
+		{
+			name:     "first match",
+			options:  []confx.Option{confx.WithConfigSearch("app", "missing", "empty", "user", "system")},
+			files:    []string{"user/app.json"},
+			expected: Config{Port: 2000},
+		},
+		{
+			name:     "all matches",
+			options:  []confx.Option{confx.WithConfigSearch("app", "user", "system"), confx.WithConfigSearchAll()},
+			files:    []string{"system/app.yaml", "user/app.json"},
+			expected: Config{Host: "system.example.com", Port: 2000},
+		},
+		{
+			name:     "no match",
+			options:  []confx.Option{confx.WithConfigSearch("app", "missing")},
+			expected: Config{Port: 8080},
+		},
+		{
+			name:     "explicit path wins",
+			confPath: "other/app.yaml",
+			options:  []confx.Option{confx.WithConfigSearch("app", "user", "system")},
+			files:    []string{"other/app.yaml"},
+			expected: Config{Host: "other.example.com", Port: 8080},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			options := append([]confx.Option{confx.WithArgs(nil), confx.WithFS(fsys)}, tt.options...)
+			loader, err := confx.Initialize(Config{Port: 8080}, options...)
+			require.NoError(t, err)
+
+			report := &confx.LoadReport{}
+			conf, err := loader(confx.WithLoadReport(context.Background(), report), tt.confPath)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, conf)
+			assert.Equal(t, tt.files, report.ConfigFiles)
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package confx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/pkg/errors"
+)
+
+// SecretMask is the placeholder printed instead of a secret value.
+const SecretMask = "******"
+
+// DefaultSecretTagName is the struct tag that marks a plain field as secret, e.g. `secret:"true"`.
+var DefaultSecretTagName = "secret"
+
+// Secret wraps a configuration value that must never be printed.
+//
+// The wrapped value is masked by fmt, JSON/YAML/text marshaling, slog and in pflag help
+// defaults, while Value returns the real value for use in code. Secret fields are
+// registered as flags and decoded from env vars and files like their underlying type.
+type Secret[T any] struct {
+	value T
+}
+
+// NewSecret wraps v as a Secret.
+func NewSecret[T any](v T) Secret[T] {
+	return Secret[T]{value: v}
+}
+
+// Value returns the real, unmasked value.
+func (s Secret[T]) Value() T {
+	return s.value
+}
+
+// String implements fmt.Stringer.
+func (s Secret[T]) String() string {
+	return SecretMask
+}
+
+// GoString implements fmt.GoStringer.
+func (s Secret[T]) GoString() string {
+	return SecretMask
+}
+
+// Format implements fmt.Formatter so that no verb can reveal the value.
+func (s Secret[T]) Format(f fmt.State, _ rune) {
+	_, _ = io.WriteString(f, SecretMask)
+}
+
+// LogValue implements slog.LogValuer.
+func (s Secret[T]) LogValue() slog.Value {
+	return slog.StringValue(SecretMask)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Secret[T]) MarshalText() ([]byte, error) {
+	return []byte(SecretMask), nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Secret[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(SecretMask)
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (s Secret[T]) MarshalYAML() (any, error) {
+	return SecretMask, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler by decoding into the wrapped value.
+func (s *Secret[T]) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &s.value)
+}
+
+func (s Secret[T]) secretValue() reflect.Value {
+	return reflect.ValueOf(&s.value).Elem()
+}
+
+func (s *Secret[T]) setSecretValue(v reflect.Value) {
+	s.value = v.Interface().(T)
+}
+
+// secretValuer is implemented by every Secret[T].
+type secretValuer interface {
+	secretValue() reflect.Value
+}
+
+var typeSecretValuer = reflect.TypeOf((*secretValuer)(nil)).Elem()
+
+func isSecretType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.Implements(typeSecretValuer)
+}
+
+func isSecretField(field reflect.StructField) bool {
+	return field.Tag.Get(DefaultSecretTagName) == "true" || isSecretType(unwrapType(field.Type))
+}
+
+// secretHookFunc returns a DecodeHookFunc that decodes data into the value wrapped by a
+// Secret, applying hook to the wrapped type.
+func secretHookFunc(tagName string, hook mapstructure.DecodeHookFunc) mapstructure.DecodeHookFunc {
+	return func(from reflect.Type, to reflect.Type, data any) (any, error) {
+		if !isSecretType(to) || from == to {
+			return data, nil
+		}
+		secret := reflect.New(to)
+		inner := reflect.New(secret.Interface().(secretValuer).secretValue().Type())
+
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			DecodeHook:       hook,
+			WeaklyTypedInput: true,
+			TagName:          tagName,
+			Result:           inner.Interface(),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create secret decoder")
+		}
+		if err := decoder.Decode(data); err != nil {
+			return nil, err
+		}
+
+		secret.Interface().(interface{ setSecretValue(reflect.Value) }).setSecretValue(inner.Elem())
+		return secret.Elem().Interface(), nil
+	}
+}
+
+// Redact returns a deep copy of v in which every field tagged `secret:"true"` is masked.
+// String fields are replaced with SecretMask and other kinds are reset to their zero value.
+// Secret[T] fields are kept as they are since they mask themselves.
+//
+// It is intended for dumping or logging a whole configuration struct.
+func Redact[T any](v T) T {
+	v = cloneSlowly(v)
+	redactRecursive(reflect.ValueOf(&v).Elem())
+	return v
+}
+
+func redactRecursive(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			redactRecursive(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			fieldValue := v.Field(i)
+			if !fieldValue.CanSet() {
+				continue
+			}
+			if field.Tag.Get(DefaultSecretTagName) == "true" {
+				redactValue(fieldValue)
+				continue
+			}
+			redactRecursive(fieldValue)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			redactRecursive(v.Index(i))
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			redactRecursive(elem)
+			v.SetMapIndex(key, elem)
+		}
+	}
+}
+
+func redactValue(v reflect.Value) {
+	if v.IsZero() {
+		return
+	}
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(SecretMask)
+	case v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.String:
+		masked := reflect.New(v.Type().Elem())
+		masked.Elem().SetString(SecretMask)
+		v.Set(masked)
+	default:
+		v.Set(reflect.Zero(v.Type()))
+	}
+}
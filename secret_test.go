@@ -0,0 +1,167 @@
+package confx_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qor5/confx"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSecretMasking(t *testing.T) {
+	s := confx.NewSecret("p@ssw0rd")
+	assert.Equal(t, "p@ssw0rd", s.Value())
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x"} {
+		assert.Equal(t, confx.SecretMask, fmt.Sprintf(format, s), format)
+	}
+	assert.Equal(t, "{Password:"+confx.SecretMask+"}", fmt.Sprintf("%+v", struct{ Password confx.Secret[string] }{s}))
+
+	bs, err := json.Marshal(map[string]any{"password": s})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"password":"`+confx.SecretMask+`"}`, string(bs))
+
+	bs, err = yaml.Marshal(map[string]any{"password": s})
+	require.NoError(t, err)
+	assert.Equal(t, "password: '"+confx.SecretMask+"'\n", string(bs))
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("loaded", "password", s)
+	assert.Contains(t, buf.String(), "password="+confx.SecretMask)
+	assert.NotContains(t, buf.String(), "p@ssw0rd")
+
+	var decoded confx.Secret[int]
+	require.NoError(t, json.Unmarshal([]byte(`42`), &decoded))
+	assert.Equal(t, 42, decoded.Value())
+}
+
+func TestSecretFields(t *testing.T) {
+	viper.Reset()
+
+	type Config struct {
+		Password   confx.Secret[string]        `confx:"password" usage:"Database password"`
+		Token      string                      `confx:"token" usage:"API token" secret:"true"`
+		TTL        confx.Secret[time.Duration] `confx:"ttl"`
+		Salt       *confx.Secret[string]       `confx:"salt"`
+		PlainValue string                      `confx:"plainValue"`
+	}
+
+	flagSet := pflag.NewFlagSet("test_secret_fields", pflag.ContinueOnError)
+	loader, err := confx.Initialize(Config{
+		Password:   confx.NewSecret("default-password"),
+		Token:      "default-token",
+		TTL:        confx.NewSecret(time.Minute),
+		PlainValue: "plain",
+	}, confx.WithFlagSet(flagSet), confx.WithEnvPrefix("APP_"))
+	require.NoError(t, err)
+
+	usages := flagSet.FlagUsages()
+	assert.NotContains(t, usages, "default-password")
+	assert.NotContains(t, usages, "default-token")
+	assert.Contains(t, usages, `--password string      Database password (default "`+confx.SecretMask+`")`)
+	assert.Contains(t, usages, `--token string         API token (default "`+confx.SecretMask+`")`)
+	assert.Contains(t, usages, `(default "plain")`)
+
+	configFilePath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFilePath, []byte("ttl: 5m\nsalt: pepper\n"), 0o644))
+
+	t.Setenv("APP_PASSWORD", "env-password")
+	require.NoError(t, flagSet.Parse([]string{"--token=flag-token"}))
+
+	conf, err := loader(context.Background(), configFilePath)
+	require.NoError(t, err)
+	assert.Equal(t, "env-password", conf.Password.Value())
+	assert.Equal(t, "flag-token", conf.Token)
+	assert.Equal(t, 5*time.Minute, conf.TTL.Value())
+	assert.Equal(t, "pepper", conf.Salt.Value())
+
+	redacted := confx.Redact(conf)
+	assert.Equal(t, confx.SecretMask, redacted.Token)
+	assert.Equal(t, "plain", redacted.PlainValue)
+	assert.Equal(t, "env-password", redacted.Password.Value()) // masks itself
+	assert.Equal(t, "flag-token", conf.Token)                  // original is untouched
+}
+
+func TestNestedSecretFlagDefaults(t *testing.T) {
+	type Upstream struct {
+		Host  string               `confx:"host"`
+		Token confx.Secret[string] `confx:"token"`
+		Key   string               `confx:"key" secret:"true"`
+	}
+	type Config struct {
+		Upstreams []Upstream                      `confx:"upstreams"`
+		Tokens    map[string]confx.Secret[string] `confx:"tokens"`
+	}
+
+	flagSet := pflag.NewFlagSet("test_nested_secret_flag_defaults", pflag.ContinueOnError)
+	loader, err := confx.Initialize(Config{
+		Upstreams: []Upstream{{Host: "a.example.com", Token: confx.NewSecret("real-token"), Key: "real-key"}},
+		Tokens:    map[string]confx.Secret[string]{"ci": confx.NewSecret("real-ci")},
+	}, confx.WithFlagSet(flagSet), confx.WithArgs([]string{}))
+	require.NoError(t, err)
+
+	usages := flagSet.FlagUsages()
+	assert.NotContains(t, usages, "real-")
+	assert.Contains(t, usages, `(default [{"host":"a.example.com","token":"`+confx.SecretMask+`","key":"`+confx.SecretMask+`"}])`)
+	assert.Contains(t, usages, `(default {"ci":"`+confx.SecretMask+`"})`)
+
+	conf, err := loader(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, conf.Upstreams, 1)
+	assert.Equal(t, "a.example.com", conf.Upstreams[0].Host)
+	assert.Equal(t, "real-token", conf.Upstreams[0].Token.Value())
+	assert.Equal(t, "real-key", conf.Upstreams[0].Key)
+	assert.Equal(t, "real-ci", conf.Tokens["ci"].Value())
+}
+
+func TestRedact(t *testing.T) {
+	type Credential struct {
+		User     string  `json:"user"`
+		Password string  `json:"password" secret:"true"`
+		Hint     *string `json:"hint" secret:"true"`
+		Pin      int     `json:"pin" secret:"true"`
+		Empty    string  `json:"empty" secret:"true"`
+	}
+	type Config struct {
+		Primary   Credential            `json:"primary"`
+		Replicas  []Credential          `json:"replicas"`
+		ByRegion  map[string]Credential `json:"byRegion"`
+		Fallback  *Credential           `json:"fallback"`
+		Unrelated string                `json:"unrelated"`
+	}
+
+	hint := "first pet"
+	conf := &Config{
+		Primary:   Credential{User: "root", Password: "p1", Hint: &hint, Pin: 1234},
+		Replicas:  []Credential{{User: "r1", Password: "p2"}},
+		ByRegion:  map[string]Credential{"eu": {User: "eu", Password: "p3"}},
+		Fallback:  &Credential{User: "fb", Password: "p4"},
+		Unrelated: "kept",
+	}
+
+	redacted := confx.Redact(conf)
+	bs, err := json.Marshal(redacted)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"primary": {"user":"root","password":"******","hint":"******","pin":0,"empty":""},
+		"replicas": [{"user":"r1","password":"******","hint":null,"pin":0,"empty":""}],
+		"byRegion": {"eu": {"user":"eu","password":"******","hint":null,"pin":0,"empty":""}},
+		"fallback": {"user":"fb","password":"******","hint":null,"pin":0,"empty":""},
+		"unrelated": "kept"
+	}`, string(bs))
+
+	assert.Equal(t, "p1", conf.Primary.Password)
+	assert.Equal(t, "first pet", hint)
+	assert.Equal(t, "p4", conf.Fallback.Password)
+}
@@ -0,0 +1,105 @@
+package confx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// fileEnvSuffix is appended to the env var of a field to read its value from a file, e.g. DB_PASSWORD_FILE.
+	fileEnvSuffix = "_FILE"
+	// credentialsDirEnv is set by systemd to the directory of the credentials of a service.
+	credentialsDirEnv = "CREDENTIALS_DIRECTORY"
+)
+
+// readFileEnv reads the value of every field env var that is unset or empty but has a
+// <ENVKEY>_FILE counterpart from the file that it points to.
+func readFileEnv(lookupEnv func(string) (string, bool), fields []*fieldInfo) (map[string]string, error) {
+	values := map[string]string{}
+	for _, f := range fields {
+		if f.nested {
+			continue
+		}
+		if val, ok := lookupEnv(f.EnvKey); ok && val != "" {
+			continue
+		}
+		path, ok := lookupEnv(f.EnvKey + fileEnvSuffix)
+		if !ok || path == "" {
+			continue
+		}
+		val, err := readSecretFile(lookupEnv, path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", f.EnvKey+fileEnvSuffix)
+		}
+		values[f.EnvKey] = val
+	}
+	return values, nil
+}
+
+// withFileEnv returns a lookup function that falls back to values for env vars that are
+// unset or empty in lookupEnv.
+func withFileEnv(lookupEnv func(string) (string, bool), values map[string]string) func(string) (string, bool) {
+	if len(values) == 0 {
+		return lookupEnv
+	}
+	return func(key string) (string, bool) {
+		if val, ok := values[key]; ok {
+			return val, true
+		}
+		return lookupEnv(key)
+	}
+}
+
+// resolveSecretFiles replaces the values of the secret fields in settings that have the form
+// @path with the content of the file at path. A leading @@ stands for a literal @.
+func resolveSecretFiles(settings map[string]any, lookupEnv func(string) (string, bool), fields []*fieldInfo) error {
+	for _, f := range fields {
+		if f.nested || !f.secret {
+			continue
+		}
+		key := strings.ToLower(f.ViperKey)
+		val, ok := getSetting(settings, key)
+		if !ok {
+			continue
+		}
+		s, ok := val.(string)
+		if !ok || !strings.HasPrefix(s, "@") {
+			continue
+		}
+		if strings.HasPrefix(s, "@@") {
+			setSetting(settings, key, s[1:])
+			continue
+		}
+		content, err := readSecretFile(lookupEnv, s[1:])
+		if err != nil {
+			return errors.Wrapf(err, "key %q", f.ViperKey)
+		}
+		setSetting(settings, key, escapeReferences(content))
+	}
+	return nil
+}
+
+// readSecretFile reads a secret from the OS filesystem, without the trailing newline.
+// Relative paths are resolved against $CREDENTIALS_DIRECTORY when it is set.
+func readSecretFile(lookupEnv func(string) (string, bool), path string) (string, error) {
+	if dir, ok := lookupEnv(credentialsDirEnv); ok && dir != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// setSetting sets the value of the dotted key in settings, which must exist.
+func setSetting(settings map[string]any, key string, val any) {
+	path := strings.Split(key, ".")
+	for _, part := range path[:len(path)-1] {
+		settings = settings[part].(map[string]any)
+	}
+	settings[path[len(path)-1]] = val
+}
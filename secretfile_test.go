@@ -0,0 +1,142 @@
+package confx_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qor5/confx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretFiles(t *testing.T) {
+	type Config struct {
+		User     string               `confx:"user"`
+		Password string               `confx:"password" secret:"true"`
+		APIKey   confx.Secret[string] `confx:"apiKey"`
+		Handle   string               `confx:"handle"`
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "user"), []byte("admin\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "password"), []byte("s3cret\r\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "api-key"), []byte("key-123"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("handle: pw=${.password}\n"), 0o600))
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		confPath string
+		expected Config
+		origins  map[string]confx.Origin
+	}{
+		{
+			name: "_FILE env vars",
+			env: map[string]string{
+				"APP_USER_FILE":     filepath.Join(dir, "user"),
+				"APP_PASSWORD_FILE": filepath.Join(dir, "password"),
+			},
+			expected: Config{User: "admin", Password: "s3cret"},
+			origins: map[string]confx.Origin{
+				"password": {Source: confx.SourceEnv, Name: "APP_PASSWORD_FILE"},
+			},
+		},
+		{
+			name: "env var over _FILE",
+			env: map[string]string{
+				"APP_USER":      "root",
+				"APP_USER_FILE": filepath.Join(dir, "user"),
+			},
+			expected: Config{User: "root"},
+			origins: map[string]confx.Origin{
+				"user": {Source: confx.SourceEnv, Name: "APP_USER"},
+			},
+		},
+		{
+			name:     "@path for secret fields",
+			args:     []string{"--password", "@" + filepath.Join(dir, "password"), "--handle", "@admin"},
+			env:      map[string]string{"APP_API_KEY": "@" + filepath.Join(dir, "api-key")},
+			expected: Config{Password: "s3cret", APIKey: confx.NewSecret("key-123"), Handle: "@admin"},
+		},
+		{
+			name:     "referenced by another key",
+			args:     []string{"--password", "@" + filepath.Join(dir, "password")},
+			confPath: filepath.Join(dir, "config.yaml"),
+			expected: Config{Password: "s3cret", Handle: "pw=s3cret"},
+		},
+		{
+			name:     "escaped @",
+			args:     []string{"--password", "@@literal"},
+			expected: Config{Password: "@literal"},
+		},
+		{
+			name: "credentials directory",
+			args: []string{"--password", "@password"},
+			env: map[string]string{
+				"CREDENTIALS_DIRECTORY": dir,
+				"APP_API_KEY_FILE":      "api-key",
+			},
+			expected: Config{Password: "s3cret", APIKey: confx.NewSecret("key-123")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			loader, err := confx.Initialize(Config{},
+				confx.WithEnvPrefix("APP_"),
+				confx.WithArgs(tt.args),
+				confx.WithLookupEnv(func(key string) (string, bool) {
+					val, ok := tt.env[key]
+					return val, ok
+				}),
+			)
+			require.NoError(t, err)
+
+			report := &confx.LoadReport{}
+			conf, err := loader(confx.WithLoadReport(context.Background(), report), tt.confPath)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected.User, conf.User)
+			assert.Equal(t, tt.expected.Password, conf.Password)
+			assert.Equal(t, tt.expected.APIKey.Value(), conf.APIKey.Value())
+			assert.Equal(t, tt.expected.Handle, conf.Handle)
+			for key, origin := range tt.origins {
+				assert.Equal(t, origin, report.Provenance[key].Origin, key)
+			}
+		})
+	}
+
+	t.Run("missing files", func(t *testing.T) {
+		for _, tt := range []struct {
+			args []string
+			env  map[string]string
+			err  string
+		}{
+			{
+				env: map[string]string{"APP_PASSWORD_FILE": filepath.Join(dir, "missing")},
+				err: "failed to read APP_PASSWORD_FILE",
+			},
+			{
+				args: []string{"--password", "@" + filepath.Join(dir, "missing")},
+				err:  `failed to read secret file: key "password"`,
+			},
+		} {
+			loader, err := confx.Initialize(Config{},
+				confx.WithEnvPrefix("APP_"),
+				confx.WithArgs(tt.args),
+				confx.WithLookupEnv(func(key string) (string, bool) {
+					val, ok := tt.env[key]
+					return val, ok
+				}),
+			)
+			require.NoError(t, err)
+
+			_, err = loader(context.Background(), "")
+			require.ErrorContains(t, err, tt.err)
+		}
+	})
+}
@@ -0,0 +1,206 @@
+package confx
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// UnknownKey is a key supplied by a config source that does not match any configuration field.
+type UnknownKey struct {
+	Source Source
+	// File is the config file that contains the key, for SourceFile.
+	File string
+	// Key is the dotted config key, or the env var name for SourceEnv.
+	Key string
+	// Suggestion is the closest known key, or empty if there is no close match.
+	Suggestion string
+}
+
+func (k UnknownKey) String() string {
+	s := k.Key
+	if k.File != "" {
+		s += " in " + k.File
+	} else if k.Source != "" {
+		s += " (" + string(k.Source) + ")"
+	}
+	if k.Suggestion != "" {
+		s += fmt.Sprintf(", did you mean %q?", k.Suggestion)
+	}
+	return s
+}
+
+// UnknownKeysError is returned in strict mode when config sources contain unknown keys.
+type UnknownKeysError struct {
+	Keys []UnknownKey
+}
+
+func (e *UnknownKeysError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("unknown config keys:")
+	for _, k := range e.Keys {
+		sb.WriteString("\n  ")
+		sb.WriteString(k.String())
+	}
+	return sb.String()
+}
+
+// findUnknownFileKeys lists the keys of the config files that do not match any registered field.
+// Keys below a leaf field, such as the entries of a map field, are considered known.
+func findUnknownFileKeys(fields []*fieldInfo, layers []*configLayer) []UnknownKey {
+	root := newKeyTree(fields)
+	known := leafKeys(fields, func(f *fieldInfo) string { return f.ViperKey })
+
+	var unknown []UnknownKey
+	for _, layer := range layers {
+		for _, key := range root.unknownKeys(layer.settings, "") {
+			unknown = append(unknown, UnknownKey{
+				Source:     SourceFile,
+				File:       layer.path,
+				Key:        key,
+				Suggestion: suggestKey(key, known),
+			})
+		}
+	}
+	return unknown
+}
+
+// unknownKeys returns the dotted paths of the leaves of settings that are not covered by n.
+func (n *keyNode) unknownKeys(settings map[string]any, prefix string) []string {
+	var unknown []string
+	for _, key := range sortedKeys(settings) {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		child := n.findChild(key)
+		if child != nil && child.isLeaf() {
+			continue
+		}
+		sub, isMap := settings[key].(map[string]any)
+		switch {
+		case child != nil && isMap:
+			unknown = append(unknown, child.unknownKeys(sub, path)...)
+		case child != nil:
+			// A nested struct set to a scalar is reported by the decoder.
+		case isMap && len(sub) > 0:
+			unknown = append(unknown, (&keyNode{}).unknownKeys(sub, path)...)
+		default:
+			unknown = append(unknown, path)
+		}
+	}
+	return unknown
+}
+
+func (n *keyNode) findChild(key string) *keyNode {
+	for _, child := range n.children {
+		if strings.EqualFold(child.key, key) {
+			return child
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// leafKeys returns the keys of the non-nested fields selected by key.
+func leafKeys(fields []*fieldInfo, key func(f *fieldInfo) string) []string {
+	var keys []string
+	for _, f := range fields {
+		if !f.nested {
+			keys = append(keys, key(f))
+		}
+	}
+	return keys
+}
+
+// suggestKey returns the known key closest to key by edit distance, ignoring case,
+// or "" if none is close enough to be a likely typo.
+func suggestKey(key string, known []string) string {
+	best, bestDist := "", -1
+	lower := strings.ToLower(key)
+	for _, candidate := range known {
+		dist := levenshtein(lower, strings.ToLower(candidate))
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = candidate, dist
+		}
+	}
+	if bestDist < 0 || bestDist > max(2, len(key)/3) {
+		return ""
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// findUnknownEnvKeys lists the env vars of environ and envFiles that carry the env prefix
+// but do not match the env var of any registered field.
+func findUnknownEnvKeys(opts *initOptions, fields []*fieldInfo, environ []string, envFiles []*envFile) []UnknownKey {
+	if opts.envPrefix == "" {
+		return nil
+	}
+	known := leafKeys(fields, func(f *fieldInfo) string { return f.EnvKey })
+	known = append(known, leafKeys(fields, func(f *fieldInfo) string { return f.EnvKey + fileEnvSuffix })...)
+	if opts.profiles {
+		known = append(known, opts.envPrefix+profileEnvName)
+	}
+
+	var names []string
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, opts.envPrefix) && !slices.Contains(known, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var unknown []UnknownKey
+	for _, name := range names {
+		unknown = append(unknown, UnknownKey{
+			Source:     SourceEnv,
+			Key:        name,
+			Suggestion: suggestKey(name, known),
+		})
+	}
+	for _, file := range envFiles {
+		for _, name := range sortedKeys(file.values) {
+			if strings.HasPrefix(name, opts.envPrefix) && !slices.Contains(known, name) {
+				unknown = append(unknown, UnknownKey{
+					Source:     SourceEnvFile,
+					File:       file.path,
+					Key:        name,
+					Suggestion: suggestKey(name, known),
+				})
+			}
+		}
+	}
+	return unknown
+}
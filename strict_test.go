@@ -0,0 +1,182 @@
+package confx_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/qor5/confx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictMode(t *testing.T) {
+	type ServerConfig struct {
+		Host string `confx:"host"`
+		Port int    `confx:"port"`
+	}
+	type Config struct {
+		Server   ServerConfig      `confx:"server"`
+		LogLevel string            `confx:"logLevel"`
+		Labels   map[string]string `confx:"labels"`
+	}
+
+	fsys := fstest.MapFS{
+		"valid.yaml":   {Data: []byte("server:\n  host: localhost\n  port: 8080\nloglevel: info\nlabels:\n  team: core\n")},
+		"typos.yaml":   {Data: []byte("sever:\n  port: 8080\nserver:\n  hots: localhost\nlogLevl: debug\n")},
+		"unknown.json": {Data: []byte(`{"database": {"dsn": "postgres://"}, "server": {"port": 9090}}`)},
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		loader, err := confx.Initialize(Config{}, confx.WithArgs(nil), confx.WithFS(fsys), confx.WithStrict())
+		require.NoError(t, err)
+
+		conf, err := loader(context.Background(), "valid.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, 8080, conf.Server.Port)
+	})
+
+	t.Run("unknown keys", func(t *testing.T) {
+		loader, err := confx.Initialize(Config{}, confx.WithArgs(nil), confx.WithFS(fsys), confx.WithStrict())
+		require.NoError(t, err)
+
+		_, err = loader(context.Background(), "typos.yaml,unknown.json")
+		var unknownErr *confx.UnknownKeysError
+		require.ErrorAs(t, err, &unknownErr)
+		assert.Equal(t, []confx.UnknownKey{
+			{Source: confx.SourceFile, File: "typos.yaml", Key: "loglevl", Suggestion: "logLevel"},
+			{Source: confx.SourceFile, File: "typos.yaml", Key: "server.hots", Suggestion: "server.host"},
+			{Source: confx.SourceFile, File: "typos.yaml", Key: "sever.port", Suggestion: "server.port"},
+			{Source: confx.SourceFile, File: "unknown.json", Key: "database.dsn"},
+		}, unknownErr.Keys)
+		assert.Equal(t, `unknown config keys:
+  loglevl in typos.yaml, did you mean "logLevel"?
+  server.hots in typos.yaml, did you mean "server.host"?
+  sever.port in typos.yaml, did you mean "server.port"?
+  database.dsn in unknown.json`, err.Error())
+	})
+
+	t.Run("lenient by default", func(t *testing.T) {
+		loader, err := confx.Initialize(Config{}, confx.WithArgs(nil), confx.WithFS(fsys))
+		require.NoError(t, err)
+
+		conf, err := loader(context.Background(), "typos.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, ServerConfig{}, conf.Server)
+	})
+}
+
+func TestUnknownEnvCheck(t *testing.T) {
+	type ServerConfig struct {
+		Host string `confx:"host"`
+		Port int    `confx:"port"`
+	}
+	type Config struct {
+		Server   ServerConfig `confx:"server"`
+		LogLevel string       `confx:"logLevel"`
+	}
+
+	env := map[string]string{
+		"CONFX_TEST_SERVER_PORT":  "9090",
+		"CONFX_TEST_SERVR_PORT":   "8081",
+		"CONFX_TEST_DATABASE_DSN": "postgres://",
+		"CONFX_TEST_PROFILE":      "dev",
+	}
+	envOptions := []confx.Option{
+		confx.WithArgs(nil),
+		confx.WithLookupEnv(func(key string) (string, bool) {
+			val, ok := env[key]
+			return val, ok
+		}),
+		confx.WithEnviron(func() []string {
+			var environ []string
+			for key, val := range env {
+				environ = append(environ, key+"="+val)
+			}
+			return environ
+		}),
+	}
+
+	t.Run("warnings", func(t *testing.T) {
+		t.Parallel()
+
+		loader, err := confx.Initialize(Config{}, append(envOptions,
+			confx.WithEnvPrefix("CONFX_TEST_"),
+			confx.WithProfiles(),
+			confx.WithUnknownEnvCheck(false),
+		)...)
+		require.NoError(t, err)
+
+		report := &confx.LoadReport{}
+		conf, err := loader(confx.WithLoadReport(context.Background(), report), "")
+		require.NoError(t, err)
+		assert.Equal(t, 9090, conf.Server.Port)
+		assert.Equal(t, []confx.UnknownKey{
+			{Source: confx.SourceEnv, Key: "CONFX_TEST_DATABASE_DSN"},
+			{Source: confx.SourceEnv, Key: "CONFX_TEST_SERVR_PORT", Suggestion: "CONFX_TEST_SERVER_PORT"},
+		}, report.UnknownKeys)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+
+		loader, err := confx.Initialize(Config{}, append(envOptions,
+			confx.WithEnvPrefix("CONFX_TEST_"),
+			confx.WithUnknownEnvCheck(true),
+		)...)
+		require.NoError(t, err)
+
+		_, err = loader(context.Background(), "")
+		require.EqualError(t, err, `unknown config keys:
+  CONFX_TEST_DATABASE_DSN (env)
+  CONFX_TEST_PROFILE (env)
+  CONFX_TEST_SERVR_PORT (env), did you mean "CONFX_TEST_SERVER_PORT"?`)
+	})
+
+	t.Run("without prefix", func(t *testing.T) {
+		t.Parallel()
+
+		loader, err := confx.Initialize(Config{}, append(envOptions,
+			confx.WithUnknownEnvCheck(true),
+		)...)
+		require.NoError(t, err)
+
+		_, err = loader(context.Background(), "")
+		require.NoError(t, err)
+	})
+}
+
+func TestUnknownEnvCheckProcessEnv(t *testing.T) {
+	type Config struct {
+		Port int `confx:"port"`
+	}
+	t.Setenv("CONFX_TEST_PORT", "9090")
+	t.Setenv("CONFX_TEST_PROT", "8081")
+
+	t.Run("process environment", func(t *testing.T) {
+		loader, err := confx.Initialize(Config{},
+			confx.WithArgs(nil),
+			confx.WithEnvPrefix("CONFX_TEST_"),
+			confx.WithUnknownEnvCheck(true),
+		)
+		require.NoError(t, err)
+
+		_, err = loader(context.Background(), "")
+		require.EqualError(t, err, `unknown config keys:
+  CONFX_TEST_PROT (env), did you mean "CONFX_TEST_PORT"?`)
+	})
+
+	t.Run("ignored with WithLookupEnv", func(t *testing.T) {
+		loader, err := confx.Initialize(Config{},
+			confx.WithArgs(nil),
+			confx.WithEnvPrefix("CONFX_TEST_"),
+			confx.WithLookupEnv(func(string) (string, bool) { return "", false }),
+			confx.WithUnknownEnvCheck(true),
+		)
+		require.NoError(t, err)
+
+		conf, err := loader(context.Background(), "")
+		require.NoError(t, err)
+		assert.Equal(t, 0, conf.Port)
+	})
+}
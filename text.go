@@ -0,0 +1,175 @@
+package confx
+
+import (
+	"encoding"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+var (
+	typeTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	typeFlagValue       = reflect.TypeOf((*pflag.Value)(nil)).Elem()
+)
+
+// textParsers parse the standard library types that are set from a single string but have
+// no text methods, or whose zero value is unusable. They return a pointer to the parsed value.
+// Other types such as net.IP, netip.Addr, netip.AddrPort, netip.Prefix and slog.Level
+// implement encoding.TextUnmarshaler.
+var textParsers = map[reflect.Type]func(s string) (any, error){
+	reflect.TypeOf(url.URL{}): func(s string) (any, error) {
+		return url.Parse(s)
+	},
+	reflect.TypeOf(net.IPNet{}): func(s string) (any, error) {
+		if s == "" {
+			return &net.IPNet{}, nil
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		return ipNet, err
+	},
+	reflect.TypeOf(time.Location{}): func(s string) (any, error) {
+		loc, err := time.LoadLocation(s)
+		if err != nil {
+			return nil, err
+		}
+		// time.Local is set up on first use, which must happen before it is copied into a field.
+		_ = loc.String()
+		return loc, nil
+	},
+	reflect.TypeOf(regexp.Regexp{}): func(s string) (any, error) {
+		return regexp.Compile(s)
+	},
+}
+
+// isTextType reports whether values of t are parsed from a single string, because t is one of
+// textParsers or *t implements pflag.Value or encoding.TextUnmarshaler. time.Time and Secret
+// keep their dedicated handling.
+func isTextType(t reflect.Type) bool {
+	if t == typeTime || isSecretType(t) {
+		return false
+	}
+	if _, ok := textParsers[t]; ok {
+		return true
+	}
+	p := reflect.PointerTo(t)
+	return p.Implements(typeFlagValue) || p.Implements(typeTextUnmarshaler)
+}
+
+// setText parses s into the value that p points to, with the parser in textParsers, with
+// pflag.Value.Set if implemented and with encoding.TextUnmarshaler otherwise.
+func setText(p reflect.Value, s string) error {
+	if parse, ok := textParsers[p.Type().Elem()]; ok {
+		v, err := parse(s)
+		if err != nil {
+			return err
+		}
+		p.Elem().Set(reflect.ValueOf(v).Elem())
+		return nil
+	}
+	if v, ok := p.Interface().(pflag.Value); ok {
+		return v.Set(s)
+	}
+	return p.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+}
+
+// formatText returns the text form of v, from pflag.Value, encoding.TextMarshaler or fmt.Stringer,
+// so that it can be parsed back by setText. The zero value without any of them is the empty string.
+func formatText(v reflect.Value) string {
+	if _, ok := textParsers[v.Type()]; ok && v.IsZero() {
+		return ""
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	switch x := p.Interface().(type) {
+	case pflag.Value:
+		return x.String()
+	case encoding.TextMarshaler:
+		if text, err := x.MarshalText(); err == nil {
+			return string(text)
+		}
+	case fmt.Stringer:
+		return x.String()
+	}
+	if v.IsZero() {
+		return ""
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// newTextFlagValue returns the flag value of a field of a text type with the default v.
+// A pflag.Value is used as is, on a copy of v.
+func newTextFlagValue(v reflect.Value) pflag.Value {
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	if fv, ok := p.Interface().(pflag.Value); ok {
+		return fv
+	}
+	return &textValue{typ: v.Type(), text: formatText(v)}
+}
+
+// textValue is the pflag.Value of a field whose type implements encoding.TextUnmarshaler.
+// It validates the flag value when set, but keeps its text, which is decoded again by
+// TextUnmarshalerHookFunc once all sources are merged.
+type textValue struct {
+	typ  reflect.Type
+	text string
+}
+
+func (t *textValue) Set(s string) error {
+	if err := setText(reflect.New(t.typ), s); err != nil {
+		return err
+	}
+	t.text = s
+	return nil
+}
+
+func (t *textValue) String() string {
+	return t.text
+}
+
+func (t *textValue) Type() string {
+	return "string"
+}
+
+// TextUnmarshalerHookFunc returns a DecodeHookFunc that decodes strings into types whose pointer
+// implements pflag.Value or encoding.TextUnmarshaler, and into url.URL, net.IPNet, time.Location
+// and regexp.Regexp. The empty string decodes to the zero value, except for these standard
+// library types, which parse it as usual, e.g. as UTC for time.Location.
+//
+// Pointers to these standard library types are set to the parsed pointer itself, so that e.g.
+// a *time.Location field set to Local or UTC is time.Local or time.UTC.
+func TextUnmarshalerHookFunc() mapstructure.DecodeHookFunc {
+	return func(from reflect.Value, to reflect.Value) (any, error) {
+		if from.Kind() != reflect.String {
+			return from.Interface(), nil
+		}
+		typ := to.Type()
+		if parse, ok := textParsers[unwrapType(typ)]; ok && typ == reflect.PointerTo(unwrapType(typ)) && to.CanSet() {
+			v, err := parse(from.String())
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to decode %s", typ.Elem())
+			}
+			to.Set(reflect.ValueOf(v))
+			// A nil pointer leaves the field as set, since mapstructure would decode into a copy.
+			return reflect.Zero(typ).Interface(), nil
+		}
+		if !isTextType(typ) {
+			return from.Interface(), nil
+		}
+		p := reflect.New(typ)
+		_, parse := textParsers[typ]
+		if s := from.String(); s != "" || parse {
+			if err := setText(p, s); err != nil {
+				return nil, errors.Wrapf(err, "failed to decode %s", typ)
+			}
+		}
+		return p.Elem().Interface(), nil
+	}
+}
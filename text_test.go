@@ -0,0 +1,344 @@
+package confx_test
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/qor5/confx"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "error"}
+
+func (l LogLevel) MarshalText() ([]byte, error) {
+	return []byte(levelNames[l]), nil
+}
+
+func (l *LogLevel) UnmarshalText(text []byte) error {
+	for i, name := range levelNames {
+		if strings.EqualFold(name, string(text)) {
+			*l = LogLevel(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log level %q", text)
+}
+
+// Endpoint only implements encoding.TextUnmarshaler and fmt.Stringer.
+type Endpoint struct {
+	Host string
+	Port string
+}
+
+func (e Endpoint) String() string {
+	if e.Host == "" {
+		return ""
+	}
+	return e.Host + ":" + e.Port
+}
+
+func (e *Endpoint) UnmarshalText(text []byte) error {
+	host, port, ok := strings.Cut(string(text), ":")
+	if !ok {
+		return fmt.Errorf("missing port in %q", text)
+	}
+	e.Host, e.Port = host, port
+	return nil
+}
+
+// Mode implements pflag.Value.
+type Mode string
+
+func (m *Mode) Set(s string) error {
+	if s != "fast" && s != "safe" {
+		return fmt.Errorf("mode must be fast or safe")
+	}
+	*m = Mode(s)
+	return nil
+}
+
+func (m *Mode) String() string { return string(*m) }
+
+func (m *Mode) Type() string { return "mode" }
+
+var _ pflag.Value = (*Mode)(nil)
+
+func TestTextFields(t *testing.T) {
+	type Config struct {
+		Level    LogLevel            `confx:"level" usage:"Log level"`
+		Levels   map[string]LogLevel `confx:"levels"`
+		Backend  Endpoint            `confx:"backend"`
+		Fallback *Endpoint           `confx:"fallback"`
+		Mode     Mode                `confx:"mode"`
+		Token    confx.Secret[Mode]  `confx:"token"`
+	}
+	def := Config{
+		Level:   LevelInfo,
+		Backend: Endpoint{Host: "localhost", Port: "8080"},
+		Mode:    "safe",
+	}
+
+	fsys := fstest.MapFS{
+		"config.yaml": {Data: []byte(`level: error
+levels:
+  db: DEBUG
+backend: db:5432
+fallback: replica:5432
+`)},
+		"invalid.yaml": {Data: []byte("backend: nohost\n")},
+	}
+
+	load := func(args []string, env map[string]string, path string) (Config, error) {
+		loader, err := confx.Initialize(def,
+			confx.WithArgs(args),
+			confx.WithFS(fsys),
+			confx.WithLookupEnv(func(key string) (string, bool) {
+				val, ok := env[key]
+				return val, ok
+			}),
+		)
+		require.NoError(t, err)
+		return loader(t.Context(), path)
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		conf, err := load(nil, nil, "")
+		require.NoError(t, err)
+		assert.Equal(t, LevelInfo, conf.Level)
+		assert.Equal(t, def.Backend, conf.Backend)
+		assert.Equal(t, &Endpoint{}, conf.Fallback)
+		assert.Equal(t, Mode("safe"), conf.Mode)
+	})
+
+	t.Run("config file", func(t *testing.T) {
+		conf, err := load(nil, nil, "config.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, LevelError, conf.Level)
+		assert.Equal(t, map[string]LogLevel{"db": LevelDebug}, conf.Levels)
+		assert.Equal(t, Endpoint{Host: "db", Port: "5432"}, conf.Backend)
+		assert.Equal(t, &Endpoint{Host: "replica", Port: "5432"}, conf.Fallback)
+	})
+
+	t.Run("env and flags", func(t *testing.T) {
+		conf, err := load(
+			[]string{"--level", "debug", "--mode", "fast", "--token", "safe"},
+			map[string]string{"BACKEND": "api:443", "LEVEL": "error"},
+			"config.yaml",
+		)
+		require.NoError(t, err)
+		assert.Equal(t, LevelDebug, conf.Level)
+		assert.Equal(t, Endpoint{Host: "api", Port: "443"}, conf.Backend)
+		assert.Equal(t, Mode("fast"), conf.Mode)
+		assert.Equal(t, Mode("safe"), conf.Token.Value())
+	})
+
+	t.Run("invalid flag", func(t *testing.T) {
+		_, err := load([]string{"--level", "trace"}, nil, "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown log level "trace"`)
+
+		_, err = load([]string{"--mode", "slow"}, nil, "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "mode must be fast or safe")
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		_, err := load(nil, nil, "invalid.yaml")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `missing port in "nohost"`)
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		flagSet := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		_, err := confx.Initialize(def, confx.WithFlagSet(flagSet))
+		require.NoError(t, err)
+		usage := flagSet.FlagUsages()
+		assert.Regexp(t, `--level string\s+Log level \(default "info"\)`, usage)
+		assert.Regexp(t, `--backend string\s+backend \(default "localhost:8080"\)`, usage)
+		assert.Regexp(t, `--mode mode\s+mode \(default safe\)`, usage)
+	})
+}
+
+func TestTextFieldsSample(t *testing.T) {
+	type Config struct {
+		Level   LogLevel `confx:"level"`
+		Backend Endpoint `confx:"backend"`
+	}
+	def := Config{Level: LevelError, Backend: Endpoint{Host: "localhost", Port: "8080"}}
+
+	data, err := confx.GenerateSample("json", def)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"level": "error", "backend": "localhost:8080"}`, string(data))
+
+	schema, err := confx.GenerateJSONSchema(def)
+	require.NoError(t, err)
+	assert.Contains(t, string(schema), `"backend": {`)
+	assert.NotContains(t, string(schema), `"Host"`)
+}
+
+func TestTextUnmarshalerHookFunc(t *testing.T) {
+	var level LogLevel
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: confx.TextUnmarshalerHookFunc(),
+		Result:     &level,
+	})
+	require.NoError(t, err)
+	require.NoError(t, decoder.Decode("error"))
+	assert.Equal(t, LevelError, level)
+
+	var endpoint Endpoint
+	decoder, err = mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: confx.TextUnmarshalerHookFunc(),
+		Result:     &endpoint,
+	})
+	require.NoError(t, err)
+	require.NoError(t, decoder.Decode(""))
+	assert.Equal(t, Endpoint{}, endpoint)
+	require.ErrorContains(t, decoder.Decode("nohost"), "failed to decode confx_test.Endpoint")
+}
+
+func TestStandardLibraryFields(t *testing.T) {
+	type Config struct {
+		URL      *url.URL       `confx:"url"`
+		Proxy    url.URL        `confx:"proxy"`
+		IP       net.IP         `confx:"ip"`
+		Network  net.IPNet      `confx:"network"`
+		Networks *net.IPNet     `confx:"networks"`
+		Pattern  *regexp.Regexp `confx:"pattern"`
+		Location *time.Location `confx:"location"`
+		Addr     netip.Addr     `confx:"addr"`
+		Listen   netip.AddrPort `confx:"listen"`
+		Prefix   netip.Prefix   `confx:"prefix"`
+		Level    slog.Level     `confx:"level"`
+	}
+	def := Config{
+		URL:      &url.URL{Scheme: "https", Host: "example.com"},
+		Location: time.UTC,
+		Listen:   netip.MustParseAddrPort("0.0.0.0:8080"),
+		Level:    slog.LevelWarn,
+	}
+
+	fsys := fstest.MapFS{
+		"config.yaml": {Data: []byte(`url: https://api.example.com/v1
+proxy: http://proxy:3128
+ip: 10.0.0.1
+network: 10.0.0.0/8
+pattern: ^user-[0-9]+$
+location: Europe/Berlin
+addr: ::1
+listen: 127.0.0.1:9090
+prefix: 192.168.0.0/16
+level: debug
+`)},
+		"invalid.yaml": {Data: []byte("network: 10.0.0.0/33\n")},
+	}
+
+	load := func(args []string, path string) (Config, error) {
+		loader, err := confx.Initialize(def,
+			confx.WithArgs(args),
+			confx.WithFS(fsys),
+			confx.WithLookupEnv(func(string) (string, bool) { return "", false }),
+		)
+		require.NoError(t, err)
+		return loader(t.Context(), path)
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		conf, err := load(nil, "")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", conf.URL.String())
+		assert.Same(t, time.UTC, conf.Location)
+		assert.Equal(t, netip.MustParseAddrPort("0.0.0.0:8080"), conf.Listen)
+		assert.Equal(t, slog.LevelWarn, conf.Level)
+		assert.Equal(t, net.IPNet{}, conf.Network)
+		assert.True(t, conf.Pattern.MatchString("anything"))
+	})
+
+	t.Run("config file", func(t *testing.T) {
+		conf, err := load(nil, "config.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, "https://api.example.com/v1", conf.URL.String())
+		assert.Equal(t, "proxy:3128", conf.Proxy.Host)
+		assert.Equal(t, net.ParseIP("10.0.0.1"), conf.IP)
+		assert.Equal(t, "10.0.0.0/8", conf.Network.String())
+		assert.True(t, conf.Pattern.MatchString("user-42"))
+		assert.False(t, conf.Pattern.MatchString("admin"))
+		assert.Equal(t, "Europe/Berlin", conf.Location.String())
+		assert.Equal(t, netip.MustParseAddr("::1"), conf.Addr)
+		assert.Equal(t, netip.MustParseAddrPort("127.0.0.1:9090"), conf.Listen)
+		assert.Equal(t, netip.MustParsePrefix("192.168.0.0/16"), conf.Prefix)
+		assert.Equal(t, slog.LevelDebug, conf.Level)
+	})
+
+	t.Run("flags", func(t *testing.T) {
+		conf, err := load([]string{"--networks", "fd00::/8", "--location", "Asia/Tokyo", "--level", "ERROR"}, "config.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, "fd00::/8", conf.Networks.String())
+		assert.Equal(t, "Asia/Tokyo", conf.Location.String())
+		assert.Equal(t, slog.LevelError, conf.Level)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := load(nil, "invalid.yaml")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `'network'`)
+		assert.Contains(t, err.Error(), "invalid CIDR address: 10.0.0.0/33")
+
+		_, err = load([]string{"--pattern", "("}, "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid argument "(" for "--pattern" flag`)
+	})
+}
+
+func TestLocalLocation(t *testing.T) {
+	// time.Local is set up from TZ on first use, so the test runs in a fresh process.
+	if os.Getenv("CONFX_TEST_LOCAL_LOCATION") == "" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestLocalLocation$")
+		cmd.Env = append(os.Environ(), "CONFX_TEST_LOCAL_LOCATION=1", "TZ=America/New_York")
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return
+	}
+
+	type Config struct {
+		Location *time.Location `confx:"location"`
+		Fallback *time.Location `confx:"fallback"`
+		Zone     time.Location  `confx:"zone"`
+	}
+	loader, err := confx.Initialize(Config{},
+		confx.WithArgs([]string{"--location", "Local", "--zone", "Local"}),
+		confx.WithFS(fstest.MapFS{"config.yaml": {Data: []byte("fallback: UTC\n")}}),
+		confx.WithLookupEnv(func(string) (string, bool) { return "", false }),
+	)
+	require.NoError(t, err)
+	conf, err := loader(t.Context(), "config.yaml")
+	require.NoError(t, err)
+
+	assert.Same(t, time.Local, conf.Location)
+	assert.Same(t, time.UTC, conf.Fallback)
+	assert.Equal(t, time.Local.String(), conf.Zone.String())
+	name, offset := time.Date(2024, 1, 15, 12, 0, 0, 0, &conf.Zone).Zone()
+	assert.Equal(t, "EST", name)
+	assert.Equal(t, -5*60*60, offset)
+}
@@ -0,0 +1,232 @@
+package confx
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+// watchDebounce is the quiet period after the last file event before a reload is triggered.
+// Editors and ConfigMap updates usually emit several events for a single logical change.
+var watchDebounce = 100 * time.Millisecond
+
+// Watcher keeps the configuration produced by a Loader in sync with its config file.
+//
+// The latest valid configuration is available through Get. If a reload fails to decode
+// or validate, the previous configuration is kept and the error is passed to the
+// OnError callbacks.
+type Watcher[T any] struct {
+	loader   Loader[T]
+	confPath string
+	current  atomic.Pointer[T]
+
+	mu       sync.Mutex
+	onChange []func(prev, next T)
+	onError  []func(err error)
+
+	fsWatcher *fsnotify.Watcher
+	watched   map[string]bool   // directories added to fsWatcher
+	files     map[string]string // clean path -> resolved real path
+	dirs      map[string]bool   // clean paths of config directories
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// Watch loads the configuration and keeps watching the config files used by the loader,
+// reloading it through the same decode and validation pipeline whenever one of them changes.
+//
+// confPath has the same meaning as for the Loader itself; when empty, the paths passed
+// via --config are watched. Files added to or removed from a config directory are picked up
+// as well. An error is returned if the initial load fails, if there is no config file to
+// watch or if the loader reads config files with WithFS, since only OS files can be watched.
+//
+// Watching stops when ctx is done or Close is called. ctx is also passed to every reload.
+func (l Loader[T]) Watch(ctx context.Context, confPath string) (*Watcher[T], error) {
+	report := &LoadReport{}
+	conf, err := l(WithLoadReport(ctx, report), confPath)
+	if err != nil {
+		return nil, err
+	}
+	if report.fs {
+		return nil, errors.New("cannot watch config files read with WithFS")
+	}
+	if len(report.ConfigFiles) == 0 && len(report.configDirs) == 0 {
+		return nil, errors.New("no config file to watch")
+	}
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create file watcher")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	w := &Watcher[T]{
+		loader:    l,
+		confPath:  confPath,
+		fsWatcher: fsWatcher,
+		watched:   make(map[string]bool),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	if err := w.watch(report); err != nil {
+		cancel()
+		_ = fsWatcher.Close()
+		return nil, err
+	}
+	w.current.Store(&conf)
+
+	go w.run(ctx)
+	return w, nil
+}
+
+// Get returns the latest valid configuration.
+func (w *Watcher[T]) Get() T {
+	return *w.current.Load()
+}
+
+// OnChange registers a callback invoked after a reload produced a new valid configuration.
+// Callbacks are invoked sequentially from the watcher goroutine.
+func (w *Watcher[T]) OnChange(fn func(prev, next T)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onChange = append(w.onChange, fn)
+}
+
+// OnError registers a callback invoked when a reload fails or the file watcher reports an error.
+// The previous configuration stays in place.
+func (w *Watcher[T]) OnError(fn func(err error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onError = append(w.onError, fn)
+}
+
+// Close stops watching and waits for the watcher goroutine to exit.
+func (w *Watcher[T]) Close() error {
+	w.cancel()
+	<-w.done
+	return nil
+}
+
+func (w *Watcher[T]) run(ctx context.Context) {
+	defer close(w.done)
+	defer w.fsWatcher.Close()
+
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return
+			}
+			if w.affected(event) {
+				timer.Reset(watchDebounce)
+			}
+		case err, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+			w.emitError(errors.Wrap(err, "file watcher error"))
+		case <-timer.C:
+			w.reload(ctx)
+		}
+	}
+}
+
+// watch replaces the watched config files and directories with those of report.
+// Watching the directories of the files also picks up atomic saves and symlink swaps
+// (e.g. Kubernetes ConfigMaps).
+func (w *Watcher[T]) watch(report *LoadReport) error {
+	w.files = make(map[string]string, len(report.ConfigFiles))
+	w.dirs = make(map[string]bool, len(report.configDirs))
+	var dirs []string
+	for _, file := range report.ConfigFiles {
+		file = filepath.Clean(file)
+		w.files[file], _ = filepath.EvalSymlinks(file)
+		dirs = append(dirs, filepath.Dir(file))
+	}
+	for _, dir := range report.configDirs {
+		dir = filepath.Clean(dir)
+		w.dirs[dir] = true
+		dirs = append(dirs, dir)
+	}
+	for _, dir := range dirs {
+		if w.watched[dir] {
+			continue
+		}
+		if err := w.fsWatcher.Add(dir); err != nil {
+			return errors.Wrapf(err, "failed to watch directory %q", dir)
+		}
+		w.watched[dir] = true
+	}
+	return nil
+}
+
+// affected reports whether event concerns one of the watched config files, either
+// directly or by changing the real path behind a symlink, or adds, removes or changes
+// a config file in one of the config directories.
+func (w *Watcher[T]) affected(event fsnotify.Event) bool {
+	name := filepath.Clean(event.Name)
+	hit := w.dirs[filepath.Dir(name)] && !event.Has(fsnotify.Chmod) &&
+		lo.Contains(configExts, strings.ToLower(filepath.Ext(name)))
+	for file, real := range w.files {
+		current, _ := filepath.EvalSymlinks(file)
+		if current != "" && current != real {
+			w.files[file] = current
+			hit = true
+			continue
+		}
+		if name == file && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create)) {
+			hit = true
+		}
+	}
+	return hit
+}
+
+// reload loads the configuration again and watches the config files it was read from,
+// which change when files are added to or removed from a config directory.
+func (w *Watcher[T]) reload(ctx context.Context) {
+	report := &LoadReport{}
+	next, err := w.loader(WithLoadReport(ctx, report), w.confPath)
+	if err != nil {
+		w.emitError(errors.Wrap(err, "failed to reload config"))
+		return
+	}
+	if err := w.watch(report); err != nil {
+		w.emitError(err)
+	}
+
+	prev := *w.current.Load()
+	if reflect.DeepEqual(prev, next) {
+		return
+	}
+	w.current.Store(&next)
+
+	w.mu.Lock()
+	callbacks := append([]func(prev, next T){}, w.onChange...)
+	w.mu.Unlock()
+	for _, fn := range callbacks {
+		fn(prev, next)
+	}
+}
+
+func (w *Watcher[T]) emitError(err error) {
+	w.mu.Lock()
+	callbacks := append([]func(err error){}, w.onError...)
+	w.mu.Unlock()
+	for _, fn := range callbacks {
+		fn(err)
+	}
+}
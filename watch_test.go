@@ -0,0 +1,170 @@
+package confx_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/qor5/confx"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type WatchConfig struct {
+	Host string `confx:"host" validate:"required"`
+	Port int    `confx:"port" validate:"gte=1,lte=65535"`
+}
+
+func TestLoaderWatch(t *testing.T) {
+	viper.Reset()
+
+	configFilePath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFilePath, []byte("host: a.example.com\nport: 8080\n"), 0o644))
+
+	flagSet := pflag.NewFlagSet("test_watch", pflag.ContinueOnError)
+	loader, err := confx.Initialize(WatchConfig{Host: "localhost", Port: 80}, confx.WithFlagSet(flagSet))
+	require.NoError(t, err)
+	require.NoError(t, flagSet.Parse(nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher, err := loader.Watch(ctx, configFilePath)
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	assert.Equal(t, WatchConfig{Host: "a.example.com", Port: 8080}, watcher.Get())
+
+	changes := make(chan [2]WatchConfig, 1)
+	errs := make(chan error, 1)
+	watcher.OnChange(func(prev, next WatchConfig) {
+		changes <- [2]WatchConfig{prev, next}
+	})
+	watcher.OnError(func(err error) {
+		errs <- err
+	})
+
+	require.NoError(t, os.WriteFile(configFilePath, []byte("host: b.example.com\nport: 9090\n"), 0o644))
+	select {
+	case change := <-changes:
+		assert.Equal(t, WatchConfig{Host: "a.example.com", Port: 8080}, change[0])
+		assert.Equal(t, WatchConfig{Host: "b.example.com", Port: 9090}, change[1])
+	case err := <-errs:
+		t.Fatalf("unexpected error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change")
+	}
+	assert.Equal(t, WatchConfig{Host: "b.example.com", Port: 9090}, watcher.Get())
+
+	// Validation failure keeps the previous snapshot.
+	require.NoError(t, os.WriteFile(configFilePath, []byte("host: c.example.com\nport: 70000\n"), 0o644))
+	select {
+	case err := <-errs:
+		assert.ErrorContains(t, err, "failed on the 'lte' tag")
+	case change := <-changes:
+		t.Fatalf("unexpected change: %v", change)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for error")
+	}
+	assert.Equal(t, WatchConfig{Host: "b.example.com", Port: 9090}, watcher.Get())
+
+	// Decoding failure keeps the previous snapshot.
+	require.NoError(t, os.WriteFile(configFilePath, []byte("host: [d.example.com\n"), 0o644))
+	select {
+	case err := <-errs:
+		assert.ErrorContains(t, err, "failed to read config")
+	case change := <-changes:
+		t.Fatalf("unexpected change: %v", change)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for error")
+	}
+	assert.Equal(t, WatchConfig{Host: "b.example.com", Port: 9090}, watcher.Get())
+
+	require.NoError(t, watcher.Close())
+}
+
+func TestLoaderWatchConfigDir(t *testing.T) {
+	viper.Reset()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "10-base.yaml"), []byte("host: a.example.com\nport: 8080\n"), 0o644))
+
+	flagSet := pflag.NewFlagSet("test_watch_config_dir", pflag.ContinueOnError)
+	loader, err := confx.Initialize(WatchConfig{Host: "localhost", Port: 80}, confx.WithFlagSet(flagSet))
+	require.NoError(t, err)
+	require.NoError(t, flagSet.Parse(nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher, err := loader.Watch(ctx, dir)
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	changes := make(chan WatchConfig, 1)
+	errs := make(chan error, 1)
+	watcher.OnChange(func(_, next WatchConfig) {
+		changes <- next
+	})
+	watcher.OnError(func(err error) {
+		errs <- err
+	})
+	waitChange := func(t *testing.T, want WatchConfig) {
+		t.Helper()
+		select {
+		case next := <-changes:
+			assert.Equal(t, want, next)
+		case err := <-errs:
+			t.Fatalf("unexpected error: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for change")
+		}
+	}
+
+	// A new fragment is merged.
+	override := filepath.Join(dir, "20-override.yaml")
+	require.NoError(t, os.WriteFile(override, []byte("port: 9090\n"), 0o644))
+	waitChange(t, WatchConfig{Host: "a.example.com", Port: 9090})
+
+	// Changes to the new fragment are picked up as well.
+	require.NoError(t, os.WriteFile(override, []byte("port: 9191\n"), 0o644))
+	waitChange(t, WatchConfig{Host: "a.example.com", Port: 9191})
+
+	// A fragment renamed away from a config extension is no longer merged.
+	require.NoError(t, os.Rename(override, override+".bak"))
+	waitChange(t, WatchConfig{Host: "a.example.com", Port: 8080})
+
+	// A removed fragment is no longer merged.
+	require.NoError(t, os.Remove(filepath.Join(dir, "10-base.yaml")))
+	waitChange(t, WatchConfig{Host: "localhost", Port: 80})
+}
+
+func TestLoaderWatchWithoutConfigFile(t *testing.T) {
+	viper.Reset()
+
+	flagSet := pflag.NewFlagSet("test_watch_without_file", pflag.ContinueOnError)
+	loader, err := confx.Initialize(WatchConfig{Host: "localhost", Port: 80}, confx.WithFlagSet(flagSet))
+	require.NoError(t, err)
+	require.NoError(t, flagSet.Parse(nil))
+
+	watcher, err := loader.Watch(context.Background(), "")
+	require.ErrorContains(t, err, "no config file to watch")
+	require.Nil(t, watcher)
+}
+
+func TestLoaderWatchWithFS(t *testing.T) {
+	loader, err := confx.Initialize(WatchConfig{Host: "localhost", Port: 80},
+		confx.WithArgs([]string{}),
+		confx.WithFS(fstest.MapFS{"config.yaml": {Data: []byte("port: 8080\n")}}),
+	)
+	require.NoError(t, err)
+
+	watcher, err := loader.Watch(context.Background(), "config.yaml")
+	require.EqualError(t, err, "cannot watch config files read with WithFS")
+	require.Nil(t, watcher)
+}